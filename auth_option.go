@@ -39,27 +39,47 @@ type authHandler struct {
 	password string
 }
 
-func (c *authHandler) login(ctx context.Context, oc *oauth2.Config) (*oauth2.Token, error) {
+// authRequest is an authorization code request protected by PKCE.
+type authRequest struct {
+	url      string
+	state    string
+	verifier string
+}
+
+func newAuthRequest(oc *oauth2.Config) (*authRequest, error) {
 	verifier, challenge, err := pkce()
 	if err != nil {
 		return nil, err
 	}
 
-	c.auth.AuthURL = oc.AuthCodeURL(state(), oauth2.AccessTypeOffline,
+	r := &authRequest{state: state(), verifier: verifier}
+	r.url = oc.AuthCodeURL(r.state, oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("code_challenge", challenge),
 		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
 	)
+	return r, nil
+}
+
+func (r *authRequest) exchange(ctx context.Context, oc *oauth2.Config, code string) (*oauth2.Token, error) {
+	return oc.Exchange(ctx, code,
+		oauth2.SetAuthURLParam("code_verifier", r.verifier),
+	)
+}
 
-	code, err := c.auth.Do(ctx, c.username, c.password)
+func (c *authHandler) login(ctx context.Context, oc *oauth2.Config) (*oauth2.Token, error) {
+	r, err := newAuthRequest(oc)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := oc.Exchange(ctx, code,
-		oauth2.SetAuthURLParam("code_verifier", verifier),
-	)
+	c.auth.AuthURL = r.url
+
+	code, err := c.auth.Do(ctx, c.username, c.password)
+	if err != nil {
+		return nil, err
+	}
 
-	return token, err
+	return r.exchange(ctx, oc, code)
 }
 
 func defaultHandler() *authHandler {
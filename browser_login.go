@@ -0,0 +1,147 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// BrowserLoginOptions configures LoginWithBrowser.
+type BrowserLoginOptions struct {
+	// OpenURL shows the authorize URL to the user, typically by opening it in
+	// a browser. Required.
+	OpenURL func(authURL string) error
+	// ReadCallbackURL returns the URL the browser was redirected to after the
+	// login, typically pasted by the user. It is used when the redirect URL of
+	// the configuration is not a loopback address, such as the void/callback
+	// page of the owner API client.
+	ReadCallbackURL func(ctx context.Context) (string, error)
+}
+
+// LoginWithBrowser logs in through Tesla's login page in a browser instead of
+// scraping it. The authorization code is received by a local HTTP server when
+// the redirect URL of oc points at a loopback address, and read from the pasted
+// callback URL otherwise. oc defaults to OAuth2Config.
+func LoginWithBrowser(ctx context.Context, oc *oauth2.Config, opts BrowserLoginOptions) (*oauth2.Token, error) {
+	if oc == nil {
+		oc = OAuth2Config
+	}
+	if opts.OpenURL == nil {
+		return nil, errors.New("browser login needs a function to open the authorize URL")
+	}
+	redirect, err := url.Parse(oc.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("redirect URL: %w", err)
+	}
+
+	r, err := newAuthRequest(oc)
+	if err != nil {
+		return nil, err
+	}
+
+	var callback *url.URL
+	if isLoopback(redirect) {
+		callback, err = receiveCallback(ctx, redirect, func() error { return opts.OpenURL(r.url) })
+	} else {
+		if opts.ReadCallbackURL == nil {
+			return nil, errors.New("browser login needs a function to read the callback URL")
+		}
+		if err := opts.OpenURL(r.url); err != nil {
+			return nil, err
+		}
+		var s string
+		if s, err = opts.ReadCallbackURL(ctx); err == nil {
+			callback, err = url.Parse(strings.TrimSpace(s))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := callbackCode(callback, r.state)
+	if err != nil {
+		return nil, err
+	}
+	return r.exchange(ctx, oc, code)
+}
+
+func isLoopback(u *url.URL) bool {
+	if u.Scheme != "http" {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// Extracts the authorization code from the callback URL after checking that it
+// answers the request with the given state.
+func callbackCode(u *url.URL, state string) (string, error) {
+	q := u.Query()
+	if e := q.Get("error"); e != "" {
+		if d := q.Get("error_description"); d != "" {
+			e += ": " + d
+		}
+		return "", fmt.Errorf("login failed: %s", e)
+	}
+	if q.Get("state") != state {
+		return "", errors.New("login callback has an unexpected state")
+	}
+	code := q.Get("code")
+	if code == "" {
+		return "", errors.New("login callback has no code")
+	}
+	return code, nil
+}
+
+// Serves the redirect URL on its loopback address until the browser is sent
+// back to it, calling open once the server is listening.
+func receiveCallback(ctx context.Context, redirect *url.URL, open func() error) (*url.URL, error) {
+	l, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	callbacks := make(chan *url.URL, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case callbacks <- req.URL:
+			fmt.Fprintln(w, "Login complete, you can close this window.")
+		default:
+			http.Error(w, "login already completed", http.StatusConflict)
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(l) }()
+	defer func() {
+		// let the handler finish its response before stopping
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	if err := open(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case u := <-callbacks:
+		return u, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
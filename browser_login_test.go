@@ -0,0 +1,119 @@
+package tesla
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+)
+
+// serveTokenExchange stands in for the token endpoint, checking the code and
+// the PKCE verifier against the challenge of the authorize URL.
+func serveTokenExchange(challenge *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(req.Form.Get("code_verifier")))
+		if req.Form.Get("code") != "code123" || base64.RawURLEncoding.EncodeToString(sum[:]) != *challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":28800}`))
+	}))
+}
+
+func freeLoopbackAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func TestBrowserLoginSpec(t *testing.T) {
+	var challenge string
+	ts := serveTokenExchange(&challenge)
+	defer ts.Close()
+
+	config := func(redirect string) *oauth2.Config {
+		return &oauth2.Config{
+			ClientID:    "ownerapi",
+			RedirectURL: redirect,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   ts.URL + "/oauth2/v3/authorize",
+				TokenURL:  ts.URL + "/oauth2/v3/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		}
+	}
+	// callbackFor returns the URL the login page redirects to for authURL.
+	callbackFor := func(authURL, state string) string {
+		u, _ := url.Parse(authURL)
+		q := u.Query()
+		challenge = q.Get("code_challenge")
+		if state == "" {
+			state = q.Get("state")
+		}
+		return fmt.Sprintf("%s?code=code123&state=%s", q.Get("redirect_uri"), state)
+	}
+
+	Convey("Should receive the code on a loopback server", t, func() {
+		addr, err := freeLoopbackAddr()
+		So(err, ShouldBeNil)
+		tok, err := LoginWithBrowser(context.Background(), config("http://"+addr+"/callback"), BrowserLoginOptions{
+			OpenURL: func(authURL string) error {
+				res, err := http.Get(callbackFor(authURL, ""))
+				if err != nil {
+					return err
+				}
+				return res.Body.Close()
+			},
+		})
+		So(err, ShouldBeNil)
+		So(tok.RefreshToken, ShouldEqual, "refresh")
+	})
+
+	Convey("Should read a pasted callback URL", t, func() {
+		var callback string
+		opts := BrowserLoginOptions{
+			OpenURL: func(authURL string) error {
+				callback = callbackFor(authURL, "")
+				return nil
+			},
+			ReadCallbackURL: func(context.Context) (string, error) {
+				return " " + callback + "\n", nil
+			},
+		}
+		tok, err := LoginWithBrowser(context.Background(), config("https://auth.tesla.com/void/callback"), opts)
+		So(err, ShouldBeNil)
+		So(tok.AccessToken, ShouldEqual, "access")
+
+		Convey("Should reject a callback for another request", func() {
+			opts.OpenURL = func(authURL string) error {
+				callback = callbackFor(authURL, "forged")
+				return nil
+			}
+			_, err := LoginWithBrowser(context.Background(), config("https://auth.tesla.com/void/callback"), opts)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "state")
+		})
+	})
+
+	Convey("Should report errors of the login page", t, func() {
+		u, _ := url.Parse("https://auth.tesla.com/void/callback?error=access_denied&error_description=denied+by+user&state=s")
+		_, err := callbackCode(u, "s")
+		So(err.Error(), ShouldEqual, "login failed: access_denied: denied by user")
+	})
+}
@@ -0,0 +1,98 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Charging states reported in ChargeState.ChargingState.
+const (
+	ChargingStateCharging     = "Charging"
+	ChargingStateComplete     = "Complete"
+	ChargingStateDisconnected = "Disconnected"
+	ChargingStateStopped      = "Stopped"
+)
+
+// ChargeSessionOptions configures a ChargeSession.
+type ChargeSessionOptions struct {
+	// TargetSoC is the battery level at which the session ends. Charging is
+	// stopped when it is below the charge limit. Defaults to the charge limit.
+	TargetSoC int
+	// PollInterval is the time between charge state polls. Defaults to one minute.
+	PollInterval time.Duration
+	// Progress is called with every polled charge state.
+	Progress func(ChargeState)
+}
+
+// ChargeSession starts charging and polls the charge state until the battery
+// reaches the target level, charging completes, charging is stopped or the
+// cable is disconnected after it began, or ctx is done. It returns the last
+// charge state.
+func (v *Vehicle) ChargeSession(ctx context.Context, opts ChargeSessionOptions) (*ChargeState, error) {
+	if opts.TargetSoC < 0 || opts.TargetSoC > 100 {
+		return nil, fmt.Errorf("target state of charge %d%% is not between 0%% and 100%%", opts.TargetSoC)
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Minute
+	}
+
+	if err := v.StartChargingContext(ctx); err != nil && !alreadyCharging(err) {
+		return nil, err
+	}
+
+	// right after charge_start the vehicle may still report that it is stopped
+	charging := false
+	for {
+		cs, err := v.chargeState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(*cs)
+		}
+
+		// a charge limit of 0 has not been reported yet
+		target, limit := opts.TargetSoC, cs.ChargeLimitSoc
+		if limit > 0 && (target == 0 || target > limit) {
+			target = limit
+		}
+		switch cs.ChargingState {
+		case ChargingStateCharging:
+			charging = true
+		case ChargingStateComplete:
+			return cs, nil
+		case ChargingStateDisconnected, ChargingStateStopped:
+			if charging {
+				return cs, nil
+			}
+		}
+		if target > 0 && cs.BatteryLevel >= target {
+			if cs.ChargingState == ChargingStateCharging && (limit == 0 || target < limit) {
+				if err := v.StopChargingContext(ctx); err != nil {
+					return cs, err
+				}
+			}
+			return cs, nil
+		}
+
+		if err := sleepContext(ctx, opts.PollInterval); err != nil {
+			return cs, err
+		}
+	}
+}
+
+// Reports whether a charge_start command failed because the vehicle is charging
+// already or has completed charging.
+func alreadyCharging(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Reason {
+	case "is_charging", "complete":
+		return true
+	}
+	return false
+}
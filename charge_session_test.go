@@ -0,0 +1,164 @@
+package tesla
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// chargingVehicle gains 10% with every charge state poll until it reaches its
+// charge limit of 90%, or stoppedAt when charging is stopped by someone else.
+type chargingVehicle struct {
+	level     int32
+	starts    int32
+	stops     int32
+	started   string
+	stoppedAt int32
+	// noLimit leaves the charge limit unreported
+	noLimit bool
+	// startingPolls report Stopped before charging begins
+	startingPolls int32
+}
+
+func (c *chargingVehicle) mux() *http.ServeMux {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/command/charge_start", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&c.starts, 1)
+		serveJSON(c.started)(w, req)
+	})
+	mux.HandleFunc("/api/1/vehicles/1234/command/charge_stop", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&c.stops, 1)
+		serveJSON(CommandResponseJSON)(w, req)
+	})
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&c.startingPolls, -1) >= 0 {
+			serveJSON(fmt.Sprintf(`{"response":{"charge_state":{"charging_state":%q,"battery_level":%d,"charge_limit_soc":90}}}`, ChargingStateStopped, atomic.LoadInt32(&c.level)))(w, req)
+			return
+		}
+		level := atomic.AddInt32(&c.level, 10)
+		state := ChargingStateCharging
+		switch {
+		case c.stoppedAt > 0 && level >= c.stoppedAt:
+			level, state = c.stoppedAt, ChargingStateStopped
+		case level >= 90:
+			level, state = 90, ChargingStateComplete
+		}
+		limit := 90
+		if c.noLimit {
+			limit = 0
+		}
+		serveJSON(fmt.Sprintf(`{"response":{"charge_state":{"charging_state":%q,"battery_level":%d,"charge_limit_soc":%d}}}`, state, level, limit))(w, req)
+	})
+	return mux
+}
+
+func TestChargeSessionSpec(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Should charge until charging completes", t, func() {
+		c := &chargingVehicle{level: 50, started: CommandResponseJSON}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		var levels []int
+		cs, err := vehicles[0].ChargeSession(ctx, ChargeSessionOptions{
+			PollInterval: time.Millisecond,
+			Progress:     func(cs ChargeState) { levels = append(levels, cs.BatteryLevel) },
+		})
+		So(err, ShouldBeNil)
+		So(cs.ChargingState, ShouldEqual, ChargingStateComplete)
+		So(levels, ShouldResemble, []int{60, 70, 80, 90})
+		So(atomic.LoadInt32(&c.starts), ShouldEqual, 1)
+		So(atomic.LoadInt32(&c.stops), ShouldEqual, 0)
+	})
+
+	Convey("Should stop charging at the target", t, func() {
+		c := &chargingVehicle{level: 50, started: `{"response":{"reason":"is_charging","result":false}}`}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		cs, err := vehicles[0].ChargeSession(ctx, ChargeSessionOptions{TargetSoC: 75, PollInterval: time.Millisecond})
+		So(err, ShouldBeNil)
+		So(cs.BatteryLevel, ShouldEqual, 80)
+		So(atomic.LoadInt32(&c.stops), ShouldEqual, 1)
+	})
+
+	Convey("Should end when charging is stopped elsewhere", t, func() {
+		c := &chargingVehicle{level: 50, started: CommandResponseJSON, stoppedAt: 70}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		cs, err := vehicles[0].ChargeSession(ctx, ChargeSessionOptions{PollInterval: time.Millisecond})
+		So(err, ShouldBeNil)
+		So(cs.ChargingState, ShouldEqual, ChargingStateStopped)
+		So(cs.BatteryLevel, ShouldEqual, 70)
+		So(atomic.LoadInt32(&c.stops), ShouldEqual, 0)
+	})
+
+	Convey("Should wait for charging to begin after starting it", t, func() {
+		c := &chargingVehicle{level: 50, started: CommandResponseJSON, startingPolls: 1}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		var states []string
+		cs, err := vehicles[0].ChargeSession(ctx, ChargeSessionOptions{
+			PollInterval: time.Millisecond,
+			Progress:     func(cs ChargeState) { states = append(states, cs.ChargingState) },
+		})
+		So(err, ShouldBeNil)
+		So(cs.ChargingState, ShouldEqual, ChargingStateComplete)
+		So(states[:2], ShouldResemble, []string{ChargingStateStopped, ChargingStateCharging})
+	})
+
+	Convey("Should keep the target while the charge limit is unreported", t, func() {
+		c := &chargingVehicle{level: 50, started: CommandResponseJSON, noLimit: true}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		cs, err := vehicles[0].ChargeSession(ctx, ChargeSessionOptions{TargetSoC: 75, PollInterval: time.Millisecond})
+		So(err, ShouldBeNil)
+		So(cs.BatteryLevel, ShouldEqual, 80)
+		So(atomic.LoadInt32(&c.stops), ShouldEqual, 1)
+
+		c = &chargingVehicle{level: 50, started: CommandResponseJSON, noLimit: true}
+		ts2 := httptest.NewServer(c.mux())
+		defer ts2.Close()
+		vehicles, err = NewTestClient(ts2).Vehicles()
+		So(err, ShouldBeNil)
+
+		cs, err = vehicles[0].ChargeSession(ctx, ChargeSessionOptions{PollInterval: time.Millisecond})
+		So(err, ShouldBeNil)
+		So(cs.ChargingState, ShouldEqual, ChargingStateComplete)
+	})
+
+	Convey("Should report failures to start charging", t, func() {
+		c := &chargingVehicle{started: `{"response":{"reason":"disconnected","result":false}}`}
+		ts := httptest.NewServer(c.mux())
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		_, err = vehicles[0].ChargeSession(ctx, ChargeSessionOptions{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "disconnected")
+		_, err = vehicles[0].ChargeSession(ctx, ChargeSessionOptions{TargetSoC: 101})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -15,6 +16,9 @@ import (
 // unknown field.
 const disallowUnknownFields = false
 
+// ownerAPIURL is the default base URL for standard API calls.
+const ownerAPIURL = "https://owner-api.teslamotors.com/api/1"
+
 // OAuth2Config is the OAuth2 configuration for authenticating with the Tesla API.
 var OAuth2Config = &oauth2.Config{
 	ClientID:    "ownerapi",
@@ -29,20 +33,26 @@ var OAuth2Config = &oauth2.Config{
 
 // Client provides the client and associated elements for interacting with the Tesla API.
 type Client struct {
-	baseURL      string
-	streamingURL string
-	hc           *http.Client
-	oc           *oauth2.Config
-	token        *oauth2.Token
-	ts           oauth2.TokenSource
-	authHandler  *authHandler
+	baseURL        string
+	streamingURL   string
+	hc             *http.Client
+	oc             *oauth2.Config
+	token          *oauth2.Token
+	ts             oauth2.TokenSource
+	authHandler    *authHandler
+	retry          *RetryPolicy
+	autoWake       *WakeOptions
+	signer         *commandSigner
+	discoverRegion bool
+	tokenStore     TokenStore
+	onToken        func(*oauth2.Token)
 }
 
-// NewClient creates a new Tesla API client. You must provided one of WithToken or WithTokenFile
-// functional options to initialize the client with an OAuth token.
+// NewClient creates a new Tesla API client. You must provided one of WithToken, WithTokenFile
+// or WithTokenStore functional options to initialize the client with an OAuth token.
 func NewClient(ctx context.Context, options ...ClientOption) (*Client, error) {
 	client := &Client{
-		baseURL:      "https://owner-api.teslamotors.com/api/1",
+		baseURL:      ownerAPIURL,
 		streamingURL: "https://streaming.vn.teslamotors.com",
 		oc:           OAuth2Config,
 	}
@@ -54,6 +64,16 @@ func NewClient(ctx context.Context, options ...ClientOption) (*Client, error) {
 		}
 	}
 
+	// load the stored token unless another option provides one
+	var stored *oauth2.Token
+	if client.tokenStore != nil && client.token == nil && client.authHandler == nil {
+		var err error
+		if stored, err = client.tokenStore.Load(); err != nil {
+			return nil, fmt.Errorf("load token: %w", err)
+		}
+		client.token = stored
+	}
+
 	// perform login if configured
 	if client.authHandler != nil {
 		if client.token != nil {
@@ -75,11 +95,27 @@ func NewClient(ctx context.Context, options ...ClientOption) (*Client, error) {
 	}
 
 	client.ts = client.oc.TokenSource(ctx, client.token)
+	if client.tokenStore != nil || client.onToken != nil {
+		ts := newStoreTokenSource(client.ts, client.tokenStore, client.onToken, stored)
+		client.ts = ts
+		if stored == nil {
+			// persist a token that did not come from the store right away
+			if _, err := ts.Token(); err != nil {
+				return nil, err
+			}
+		}
+	}
 	client.hc = oauth2.NewClient(ctx, client.ts)
 
 	// use the Tesla UA transport
 	client.hc.Transport = &Transport{RoundTripper: client.hc.Transport}
 
+	if client.discoverRegion {
+		if err := client.useDiscoveredRegion(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	return client, nil
 }
 
@@ -89,14 +125,17 @@ func (c Client) Token() (*oauth2.Token, error) {
 }
 
 // Calls an HTTP GET
-func (c Client) get(url string) ([]byte, error) {
-	req, _ := http.NewRequest("GET", url, nil)
+func (c Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
 	return c.processRequest(req)
 }
 
 // getJSON performs an HTTP GET and then unmarshals the result into the provided struct.
-func (c Client) getJSON(url string, out interface{}) error {
-	body, err := c.get(url)
+func (c Client) getJSON(ctx context.Context, url string, out interface{}) error {
+	body, err := c.get(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -111,23 +150,52 @@ func (c Client) getJSON(url string, out interface{}) error {
 }
 
 // Calls an HTTP POST with a JSON body
-func (c Client) post(url string, body []byte) ([]byte, error) {
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+func (c Client) post(ctx context.Context, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
 	return c.processRequest(req)
 }
 
-// Processes a HTTP POST/PUT request
+// Processes a HTTP POST/PUT request, retrying it according to the client's retry policy
 func (c Client) processRequest(req *http.Request) ([]byte, error) {
 	c.setHeaders(req)
+	for attempt := 1; ; attempt++ {
+		res, body, err := c.do(req)
+		if err == nil {
+			return body, nil
+		}
+		delay, retry := c.retry.next(req, res, err, attempt)
+		if !retry {
+			return nil, err
+		}
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Performs a single HTTP request and reads the response body
+func (c Client) do(req *http.Request) (*http.Response, []byte, error) {
 	res, err := c.hc.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return nil, errors.New(res.Status)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return res, nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return res, nil, newAPIError(req, res, body)
 	}
-	return io.ReadAll(res.Body)
+	return res, body, nil
 }
 
 // Sets the required headers for calls to the Tesla API
@@ -3,10 +3,13 @@ package tesla
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,10 +34,12 @@ func NewTestClient(ts *httptest.Server) *Client {
 		Scopes: []string{"openid", "email", "offline_access"},
 	}
 
+	tokenSource := config.TokenSource(ctx, tok)
 	client := &Client{
 		baseURL:      ts.URL + "/api/1",
 		streamingURL: ts.URL,
-		hc:           config.Client(ctx, tok),
+		hc:           oauth2.NewClient(ctx, tokenSource),
+		ts:           tokenSource,
 	}
 	return client
 }
@@ -53,6 +58,52 @@ func TestClientSpec(t *testing.T) {
 	})
 }
 
+func TestClientContextSpec(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	hang := func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-release:
+		}
+	}
+
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", hang)
+	mux.HandleFunc("/api/1/vehicles/1234/command/honk_horn", hang)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.VehiclesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+
+	Convey("Should abort a state request when the deadline passes", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := vehicle.DataContext(ctx)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+	})
+
+	Convey("Should abort a command when the context is canceled", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		err := vehicle.HonkHornContext(ctx)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+	})
+
+	Convey("Should not send a request with an already canceled context", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.VehiclesContext(ctx)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+	})
+}
+
 var testMux = &http.ServeMux{}
 
 func serveHTTP(_ *testing.T) *httptest.Server {
@@ -139,14 +190,14 @@ func init() {
 	}))
 
 	testMux.HandleFunc("/api/1/vehicles/1234/command/set_charge_limit", serveCheck(func(req *http.Request, body []byte) error {
-		if string(body) != `{"percent": 50}` {
+		if string(body) != `{"percent":50}` {
 			return fmt.Errorf("unexpected body %s", body)
 		}
 		return nil
 	}))
 
 	testMux.HandleFunc("/api/1/vehicles/1234/command/set_charging_amps", serveCheck(func(req *http.Request, body []byte) error {
-		if string(body) != `{"charging_amps": 12}` {
+		if string(body) != `{"charging_amps":12}` {
 			return fmt.Errorf("unexpected body %s", body)
 		}
 		return nil
@@ -190,19 +241,121 @@ func init() {
 
 	testMux.HandleFunc("/api/1/vehicles/1234/command/sun_roof_control", serveCheck(func(req *http.Request, body []byte) error {
 		switch string(body) {
-		case `{"state": "vent", "percent":0}`:
-		case `{"state": "open", "percent":0}`:
-		case `{"state": "move", "percent":50}`:
-		case `{"state": "close", "percent":0}`:
+		case `{"state":"vent","percent":0}`:
+		case `{"state":"open","percent":0}`:
+		case `{"state":"move","percent":50}`:
+		case `{"state":"close","percent":0}`:
 		default:
 			return fmt.Errorf("unknown request %s", body)
 		}
 		return nil
 	}))
 
+	for command, want := range map[string]string{
+		"set_preconditioning_max":              `{"on":true}`,
+		"set_climate_keeper_mode":              `{"climate_keeper_mode":2}`,
+		"set_bioweapon_mode":                   `{"on":false,"manual_override":true}`,
+		"set_cabin_overheat_protection":        `{"on":true,"fan_only":true}`,
+		"set_cop_temp":                         `{"cop_temp":1}`,
+		"remote_seat_cooler_request":           `{"seat_position":2,"seat_cooler_level":3}`,
+		"remote_auto_seat_climate_request":     `{"auto_seat_position":1,"auto_climate_on":true}`,
+		"set_scheduled_charging":               `{"enable":true,"time":90}`,
+		"set_scheduled_departure":              `{"enable":true,"departure_time":450,"preconditioning_enabled":true,"preconditioning_weekdays_only":true,"off_peak_charging_enabled":true,"off_peak_charging_weekdays_only":false,"end_off_peak_time":360}`,
+		"add_charge_schedule":                  `{"days_of_week":"Monday,Friday","enabled":true,"start_enabled":true,"start_time":1320,"end_enabled":false,"end_time":0,"one_time":false,"lat":35.1,"lon":20.2}`,
+		"remove_charge_schedule":               `{"id":7}`,
+		"add_precondition_schedule":            `{"id":3,"days_of_week":"Weekdays","enabled":true,"precondition_time":480,"one_time":true,"lat":1.5,"lon":2.5}`,
+		"remove_precondition_schedule":         `{"id":3}`,
+		"navigation_gps_request":               `{"lat":35.1,"lon":-20.2,"order":1}`,
+		"navigation_sc_request":                `{"id":42,"order":0}`,
+		"navigation_waypoints_request":         `{"waypoints":"refId:ChIJa,refId:ChIJb"}`,
+		"adjust_volume":                        `{"volume":4.5}`,
+		"speed_limit_activate":                 `{"pin":"1234"}`,
+		"speed_limit_deactivate":               `{"pin":"1234"}`,
+		"speed_limit_clear_pin":                `{"pin":"1234"}`,
+		"speed_limit_set_limit":                `{"limit_mph":70}`,
+		"set_valet_mode":                       `{"on":true,"password":"0042"}`,
+		"schedule_software_update":             `{"offset_sec":7200}`,
+		"cancel_software_update":               ``,
+		"remote_seat_heater_request":           `{"heater":0,"level":3}`,
+		"remote_steering_wheel_heater_request": `{"on":true}`,
+		"actuate_trunk":                        `{"which_trunk":"rear"}`,
+		"charge_port_door_close":               ``,
+		"remote_boombox":                       `{"sound":2000}`,
+		"guest_mode":                           `{"enable":true}`,
+		"set_pin_to_drive":                     `{"on":true,"password":"1234"}`,
+		"reset_pin_to_drive_pin":               ``,
+		"media_toggle_playback":                ``,
+		"media_next_track":                     ``,
+		"media_prev_track":                     ``,
+		"media_next_fav":                       ``,
+		"media_prev_fav":                       ``,
+		"media_volume_up":                      ``,
+		"media_volume_down":                    ``,
+	} {
+		want := want
+		testMux.HandleFunc("/api/1/vehicles/1234/command/"+command, serveCheck(func(req *http.Request, body []byte) error {
+			if string(body) != want {
+				return fmt.Errorf("unexpected body %s", body)
+			}
+			return nil
+		}))
+	}
+
+	testMux.HandleFunc("/api/1/vehicles/1234/command/window_control", serveCheck(func(req *http.Request, body []byte) error {
+		switch string(body) {
+		case `{"command":"vent","lat":0,"lon":0}`:
+		case `{"command":"close","lat":35.1,"lon":20.2}`:
+		default:
+			return fmt.Errorf("unknown request %s", body)
+		}
+		return nil
+	}))
+
+	// vehicle 5678 has an open powered liftgate, vehicle 5679 a closed one
+	testMux.HandleFunc("/api/1/vehicles/5678/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+		if !strings.Contains(req.URL.Query().Get("endpoints"), "closures_state") {
+			serveJSON(LiftgateJSON)(w, req)
+			return
+		}
+		serveJSON(OpenLiftgateJSON)(w, req)
+	})
+	testMux.HandleFunc("/api/1/vehicles/5678/command/actuate_trunk", serveCheck(func(req *http.Request, body []byte) error {
+		if string(body) != `{"which_trunk":"rear"}` {
+			return fmt.Errorf("unexpected body %s", body)
+		}
+		atomic.AddInt32(&liftgateActuations, 1)
+		return nil
+	}))
+	testMux.HandleFunc("/api/1/vehicles/5679/vehicle_data", serveJSON(ClosedLiftgateJSON))
+	testMux.HandleFunc("/api/1/vehicles/5679/command/actuate_trunk", serveCheck(func(req *http.Request, body []byte) error {
+		return errors.New("the liftgate is closed already")
+	}))
+
+	testMux.HandleFunc("/api/1/vehicles/1234/command/erase_user_data", serveJSON(`{"response":{"reason":"not_parked","result":false}}`))
+	testMux.HandleFunc("/api/1/vehicles/1234/command/set_vehicle_name", serveCheck(func(req *http.Request, body []byte) error {
+		if string(body) != `{"vehicle_name":"Macak"}` {
+			return fmt.Errorf("unexpected body %s", body)
+		}
+		return nil
+	}))
+
+	testMux.HandleFunc("/api/1/vehicles/1234/command/share", serveCheck(func(req *http.Request, body []byte) error {
+		share := &ShareRequest{}
+		if err := json.Unmarshal(body, share); err != nil {
+			return err
+		}
+		if share.Type != "share_ext_content_raw" || share.Locale != "en-US" || share.TimestampMs == "" {
+			return fmt.Errorf("unexpected share request %s", body)
+		}
+		if g, w := share.Value["android.intent.extra.TEXT"], "1 Infinite Loop, Cupertino"; g != w {
+			return fmt.Errorf("unexpected text: got %q want %q", g, w)
+		}
+		return nil
+	}))
+
 	testMux.HandleFunc("/api/1/vehicles/1234/command/set_sentry_mode", serveCheck(func(req *http.Request, body []byte) error {
 		switch string(body) {
-		case `{"on":"true"}`:
+		case `{"on":true}`, `{"on":false}`:
 		default:
 			return fmt.Errorf("unknown request %s", body)
 		}
@@ -16,6 +16,7 @@ import (
 	"github.com/bogosj/tesla"
 	"github.com/manifoldco/promptui"
 	"github.com/skratchdot/open-golang/open"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -124,13 +125,58 @@ func shortLongStringFlag(name, short, value, usage string) *string {
 	return s
 }
 
-func login(ctx context.Context) error {
-	out := shortLongStringFlag("out", "o", "", "Token JSON output path. Leave blank or use '-' to write to stdout.")
-	flag.Parse()
+func readCallbackURL(ctx context.Context) (string, error) {
+	fmt.Println("After logging in, the browser shows a \"Page Not Found\" error. Copy the URL of that page.")
+	type result struct {
+		url string
+		err error
+	}
+	// the prompt cannot be interrupted, so stop waiting for it instead
+	done := make(chan result, 1)
+	go func() {
+		url, err := (&promptui.Prompt{
+			Label:   "Callback URL",
+			Pointer: promptui.PipeCursor,
+			Validate: func(s string) error {
+				if !strings.Contains(s, "code=") {
+					return errors.New("the URL has no code")
+				}
+				return nil
+			},
+		}).Run()
+		done <- result{url, err}
+	}()
+	select {
+	case r := <-done:
+		return r.url, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func openAuthURL(authURL string) error {
+	fmt.Printf("Log in at %s\n", authURL)
+	if err := open.Run(authURL); err != nil {
+		fmt.Println("Cannot open a browser, open the URL above yourself.")
+	}
+	return nil
+}
+
+func browserLogin(ctx context.Context, redirect string) (*oauth2.Token, error) {
+	oc := *tesla.OAuth2Config
+	if redirect != "" {
+		oc.RedirectURL = redirect
+	}
+	return tesla.LoginWithBrowser(ctx, &oc, tesla.BrowserLoginOptions{
+		OpenURL:         openAuthURL,
+		ReadCallbackURL: readCallbackURL,
+	})
+}
 
+func credentialsLogin(ctx context.Context) (*oauth2.Token, error) {
 	username, password, err := getUsernameAndPassword()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	client, err := tesla.NewClient(
@@ -140,10 +186,31 @@ func login(ctx context.Context) error {
 		tesla.WithCredentials(username, password),
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	t, err := client.Token()
+	return client.Token()
+}
+
+func shortLongBoolFlag(name, short string, value bool, usage string) *bool {
+	b := flag.Bool(name, value, usage)
+	flag.BoolVar(b, short, value, usage)
+	return b
+}
+
+func login(ctx context.Context) error {
+	out := shortLongStringFlag("out", "o", "", "Token JSON output path. Leave blank or use '-' to write to stdout.")
+	browser := shortLongBoolFlag("browser", "b", false, "Log in through the browser instead of entering the credentials here.")
+	redirect := flag.String("redirect", "", "Redirect URL for browser login. A loopback URL such as http://localhost:8080/callback receives the login without pasting.")
+	flag.Parse()
+
+	var t *oauth2.Token
+	var err error
+	if *browser {
+		t, err = browserLogin(ctx, *redirect)
+	} else {
+		t, err = credentialsLogin(ctx)
+	}
 	if err != nil {
 		return err
 	}
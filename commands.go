@@ -1,6 +1,7 @@
 package tesla
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,88 +25,138 @@ type AutoParkRequest struct {
 }
 
 // SentryData shows whether Sentry is on.
+//
+// Deprecated: the API expects a JSON boolean, use OnRequest.
 type SentryData struct {
 	Mode string `json:"on"`
 }
 
+// OnRequest is the body of commands that turn a feature on or off.
+type OnRequest struct {
+	On bool `json:"on"`
+}
+
 // AutoparkAbort causes the vehicle to abort the Autopark request.
 func (v *Vehicle) AutoparkAbort() error {
-	return v.autoPark("abort")
+	return v.AutoparkAbortContext(context.Background())
+}
+
+// AutoparkAbortContext is like AutoparkAbort but uses ctx for the request.
+func (v *Vehicle) AutoparkAbortContext(ctx context.Context) error {
+	return v.autoPark(ctx, "abort")
 }
 
 // AutoparkForward causes the vehicle to pull forward.
 func (v *Vehicle) AutoparkForward() error {
-	return v.autoPark("start_forward")
+	return v.AutoparkForwardContext(context.Background())
+}
+
+// AutoparkForwardContext is like AutoparkForward but uses ctx for the request.
+func (v *Vehicle) AutoparkForwardContext(ctx context.Context) error {
+	return v.autoPark(ctx, "start_forward")
 }
 
 // AutoparkReverse causes the vehicle to go in reverse.
 func (v *Vehicle) AutoparkReverse() error {
-	return v.autoPark("start_reverse")
+	return v.AutoparkReverseContext(context.Background())
+}
+
+// AutoparkReverseContext is like AutoparkReverse but uses ctx for the request.
+func (v *Vehicle) AutoparkReverseContext(ctx context.Context) error {
+	return v.autoPark(ctx, "start_reverse")
 }
 
 // Performs the actual auto park/summon request for the vehicle
-func (v *Vehicle) autoPark(action string) error {
+func (v *Vehicle) autoPark(ctx context.Context, action string) error {
 	apiURL := v.commandPath("autopark_request")
-	data, _ := v.Data()
+	lat, lon, err := v.location(ctx)
+	if err != nil {
+		return err
+	}
 	autoParkRequest := &AutoParkRequest{
 		VehicleID: v.VehicleID,
-		Lat:       data.Response.DriveState.Latitude,
-		Lon:       data.Response.DriveState.Longitude,
+		Lat:       lat,
+		Lon:       lon,
 		Action:    action,
 	}
 	body, _ := json.Marshal(autoParkRequest)
 
-	_, err := v.sendCommand(apiURL, body)
+	_, err = v.sendCommand(ctx, apiURL, body)
 	return err
 }
 
+// Fetches the current location of the vehicle
+func (v *Vehicle) location(ctx context.Context) (lat, lon float64, err error) {
+	data, err := v.DataFor(ctx, DataEndpointDriveState, DataEndpointLocationData)
+	if err != nil {
+		return 0, 0, err
+	}
+	if data.Response.DriveState == nil {
+		return 0, 0, errors.New("vehicle did not report its drive state")
+	}
+	return data.Response.DriveState.Latitude, data.Response.DriveState.Longitude, nil
+}
+
+// Fetches the current charge state of the vehicle
+func (v *Vehicle) chargeState(ctx context.Context) (*ChargeState, error) {
+	data, err := v.DataFor(ctx, DataEndpointChargeState)
+	if err != nil {
+		return nil, err
+	}
+	if data.Response.ChargeState == nil {
+		return nil, errors.New("vehicle did not report its charge state")
+	}
+	return data.Response.ChargeState, nil
+}
+
 // EnableSentry enables Sentry Mode
 func (v *Vehicle) EnableSentry() error {
-	apiURL := v.commandPath("set_sentry_mode")
-	sentryRequest := &SentryData{
-		Mode: "true",
-	}
+	return v.EnableSentryContext(context.Background())
+}
 
-	body, _ := json.Marshal(sentryRequest)
-	_, err := v.sendCommand(apiURL, body)
-	return err
+// EnableSentryContext is like EnableSentry but uses ctx for the request.
+func (v *Vehicle) EnableSentryContext(ctx context.Context) error {
+	return v.SetSentryMode(ctx, true)
 }
 
-// TBD based on Github issue #7
-// Toggles defrost on and off, locations values are 'front' or 'rear'
-// func (v *Vehicle) Defrost(location string, state bool) error {
-// 	command := location + "_defrost_"
-// 	if state {
-// 		command += "on"
-// 	} else {
-// 		command += "off"
-// 	}
-// 	apiURL := v.c.URL + "/vehicles/" + strconv.FormatInt(v.ID, 10) + "/command/" + command
-// 	fmt.Println(apiURL)
-// 	_, err := v.sendCommand(apiURL, nil)
-// 	return err
-// }
+// SetSentryMode turns Sentry Mode on or off.
+func (v *Vehicle) SetSentryMode(ctx context.Context, on bool) error {
+	return v.sendCommandRequest(ctx, "set_sentry_mode", &OnRequest{On: on})
+}
 
 // TriggerHomelink opens and closes the configured Homelink garage door of the vehicle
 // keep in mind this is a toggle and the garage door state is unknown
 // a major limitation of Homelink.
 func (v *Vehicle) TriggerHomelink() error {
+	return v.TriggerHomelinkContext(context.Background())
+}
+
+// TriggerHomelinkContext is like TriggerHomelink but uses ctx for the request.
+func (v *Vehicle) TriggerHomelinkContext(ctx context.Context) error {
 	apiURL := v.commandPath("trigger_homelink")
-	data, _ := v.Data()
+	lat, lon, err := v.location(ctx)
+	if err != nil {
+		return err
+	}
 	autoParkRequest := &AutoParkRequest{
-		Lat: data.Response.DriveState.Latitude,
-		Lon: data.Response.DriveState.Longitude,
+		Lat: lat,
+		Lon: lon,
 	}
 	body, _ := json.Marshal(autoParkRequest)
 
-	_, err := v.sendCommand(apiURL, body)
+	_, err = v.sendCommand(ctx, apiURL, body)
 	return err
 }
 
 // Wakeup wakes up the vehicle when it is powered off.
 func (v *Vehicle) Wakeup() (*Vehicle, error) {
+	return v.WakeupContext(context.Background())
+}
+
+// WakeupContext is like Wakeup but uses ctx for the request.
+func (v *Vehicle) WakeupContext(ctx context.Context) (*Vehicle, error) {
 	apiURL := v.wakePath()
-	body, err := v.sendCommand(apiURL, nil)
+	body, err := v.postCommand(ctx, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -119,87 +170,182 @@ func (v *Vehicle) Wakeup() (*Vehicle, error) {
 
 // OpenChargePort opens the charge port so you may insert your charging cable.
 func (v *Vehicle) OpenChargePort() error {
+	return v.OpenChargePortContext(context.Background())
+}
+
+// OpenChargePortContext is like OpenChargePort but uses ctx for the request.
+func (v *Vehicle) OpenChargePortContext(ctx context.Context) error {
 	apiURL := v.commandPath("charge_port_door_open")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
+	return err
+}
+
+// CloseChargePort closes the charge port door of vehicles with a motorized charge port.
+func (v *Vehicle) CloseChargePort() error {
+	return v.CloseChargePortContext(context.Background())
+}
+
+// CloseChargePortContext is like CloseChargePort but uses ctx for the request.
+func (v *Vehicle) CloseChargePortContext(ctx context.Context) error {
+	apiURL := v.commandPath("charge_port_door_close")
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // ResetValetPIN resets the PIN set for valet mode, if set.
 func (v *Vehicle) ResetValetPIN() error {
+	return v.ResetValetPINContext(context.Background())
+}
+
+// ResetValetPINContext is like ResetValetPIN but uses ctx for the request.
+func (v *Vehicle) ResetValetPINContext(ctx context.Context) error {
 	apiURL := v.commandPath("reset_valet_pin")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // SetChargeLimitStandard sets the charge limit to the standard setting.
 func (v *Vehicle) SetChargeLimitStandard() error {
+	return v.SetChargeLimitStandardContext(context.Background())
+}
+
+// SetChargeLimitStandardContext is like SetChargeLimitStandard but uses ctx for the request.
+func (v *Vehicle) SetChargeLimitStandardContext(ctx context.Context) error {
 	apiURL := v.commandPath("charge_standard")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // SetChargeLimitMax sets the charge limit to the max limit.
 func (v *Vehicle) SetChargeLimitMax() error {
+	return v.SetChargeLimitMaxContext(context.Background())
+}
+
+// SetChargeLimitMaxContext is like SetChargeLimitMax but uses ctx for the request.
+func (v *Vehicle) SetChargeLimitMaxContext(ctx context.Context) error {
 	apiURL := v.commandPath("charge_max_range")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
-// SetChargeLimit set the charge limit to a custom percentage.
+// ChargeLimitRequest is the body of the set_charge_limit command.
+type ChargeLimitRequest struct {
+	Percent int `json:"percent"`
+}
+
+// SetChargeLimit set the charge limit to a custom percentage, which must be
+// within the ChargeLimitSocMin and ChargeLimitSocMax reported by the vehicle.
 func (v *Vehicle) SetChargeLimit(percent int) error {
-	apiURL := v.commandPath("set_charge_limit")
-	payload := `{"percent": ` + strconv.Itoa(percent) + `}`
-	_, err := v.c.post(apiURL, []byte(payload))
-	return err
+	return v.SetChargeLimitContext(context.Background(), percent)
+}
+
+// SetChargeLimitContext is like SetChargeLimit but uses ctx for the request.
+func (v *Vehicle) SetChargeLimitContext(ctx context.Context, percent int) error {
+	cs, err := v.chargeState(ctx)
+	if err != nil {
+		return err
+	}
+	if (cs.ChargeLimitSocMin != 0 && percent < cs.ChargeLimitSocMin) || (cs.ChargeLimitSocMax != 0 && percent > cs.ChargeLimitSocMax) {
+		return fmt.Errorf("charge limit %d%% is not between %d%% and %d%%", percent, cs.ChargeLimitSocMin, cs.ChargeLimitSocMax)
+	}
+	return v.sendCommandRequest(ctx, "set_charge_limit", &ChargeLimitRequest{Percent: percent})
+}
+
+// ChargingAmpsRequest is the body of the set_charging_amps command.
+type ChargingAmpsRequest struct {
+	ChargingAmps int `json:"charging_amps"`
 }
 
-// SetChargingAmps set the charging amps to a specific value.
+// SetChargingAmps set the charging amps to a specific value, which must not
+// exceed the ChargeCurrentRequestMax reported by the vehicle.
 func (v *Vehicle) SetChargingAmps(amps int) error {
-	apiURL := v.commandPath("set_charging_amps")
-	payload := `{"charging_amps": ` + strconv.Itoa(amps) + `}`
-	_, err := v.c.post(apiURL, []byte(payload))
-	return err
+	return v.SetChargingAmpsContext(context.Background(), amps)
+}
+
+// SetChargingAmpsContext is like SetChargingAmps but uses ctx for the request.
+func (v *Vehicle) SetChargingAmpsContext(ctx context.Context, amps int) error {
+	if amps < 0 {
+		return fmt.Errorf("negative charging amps %d", amps)
+	}
+	cs, err := v.chargeState(ctx)
+	if err != nil {
+		return err
+	}
+	if cs.ChargeCurrentRequestMax != 0 && amps > cs.ChargeCurrentRequestMax {
+		return fmt.Errorf("charging amps %d exceed the maximum of %d", amps, cs.ChargeCurrentRequestMax)
+	}
+	return v.sendCommandRequest(ctx, "set_charging_amps", &ChargingAmpsRequest{ChargingAmps: amps})
 }
 
 // StartCharging starts the charging of the vehicle after you have inserted the charging cable.
 func (v *Vehicle) StartCharging() error {
+	return v.StartChargingContext(context.Background())
+}
+
+// StartChargingContext is like StartCharging but uses ctx for the request.
+func (v *Vehicle) StartChargingContext(ctx context.Context) error {
 	apiURL := v.commandPath("charge_start")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // StopCharging stops the charging of the vehicle.
 func (v *Vehicle) StopCharging() error {
+	return v.StopChargingContext(context.Background())
+}
+
+// StopChargingContext is like StopCharging but uses ctx for the request.
+func (v *Vehicle) StopChargingContext(ctx context.Context) error {
 	apiURL := v.commandPath("charge_stop")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // FlashLights flashes the lights of the vehicle.
 func (v *Vehicle) FlashLights() error {
+	return v.FlashLightsContext(context.Background())
+}
+
+// FlashLightsContext is like FlashLights but uses ctx for the request.
+func (v *Vehicle) FlashLightsContext(ctx context.Context) error {
 	apiURL := v.commandPath("flash_lights")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // HonkHorn honks the horn of the vehicle.
 func (v *Vehicle) HonkHorn() error {
+	return v.HonkHornContext(context.Background())
+}
+
+// HonkHornContext is like HonkHorn but uses ctx for the request.
+func (v *Vehicle) HonkHornContext(ctx context.Context) error {
 	apiURL := v.commandPath("honk_horn")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // UnlockDoors unlock the vehicle's doors.
 func (v *Vehicle) UnlockDoors() error {
+	return v.UnlockDoorsContext(context.Background())
+}
+
+// UnlockDoorsContext is like UnlockDoors but uses ctx for the request.
+func (v *Vehicle) UnlockDoorsContext(ctx context.Context) error {
 	apiURL := v.commandPath("door_unlock")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
 // LockDoors locks the doors of the vehicle.
 func (v *Vehicle) LockDoors() error {
+	return v.LockDoorsContext(context.Background())
+}
+
+// LockDoorsContext is like LockDoors but uses ctx for the request.
+func (v *Vehicle) LockDoorsContext(ctx context.Context) error {
 	apiURL := v.commandPath("door_lock")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
@@ -211,95 +357,474 @@ type tempRequest struct {
 // SetTemperature sets the temperature of the vehicle, where you may set the driver
 // zone and the passenger zone to separate temperatures.
 func (v *Vehicle) SetTemperature(driver float64, passenger float64) error {
+	return v.SetTemperatureContext(context.Background(), driver, passenger)
+}
+
+// SetTemperatureContext is like SetTemperature but uses ctx for the request.
+func (v *Vehicle) SetTemperatureContext(ctx context.Context, driver float64, passenger float64) error {
 	driveTemp := strconv.FormatFloat(driver, 'f', -1, 32)
 	passengerTemp := strconv.FormatFloat(passenger, 'f', -1, 32)
-	apiURL := v.commandPath("set_temps")
-	b, err := json.Marshal(&tempRequest{driveTemp, passengerTemp})
-	if err != nil {
-		return err
-	}
-	_, err = v.c.post(apiURL, b)
-	return err
+	return v.sendCommandRequest(ctx, "set_temps", &tempRequest{driveTemp, passengerTemp})
 }
 
 // StartAirConditioning starts the air conditioning in the vehicle.
 func (v *Vehicle) StartAirConditioning() error {
+	return v.StartAirConditioningContext(context.Background())
+}
+
+// StartAirConditioningContext is like StartAirConditioning but uses ctx for the request.
+func (v *Vehicle) StartAirConditioningContext(ctx context.Context) error {
 	url := v.commandPath("auto_conditioning_start")
-	_, err := v.sendCommand(url, nil)
+	_, err := v.sendCommand(ctx, url, nil)
 	return err
 }
 
 // StopAirConditioning stops the air conditioning in the vehicle.
 func (v *Vehicle) StopAirConditioning() error {
+	return v.StopAirConditioningContext(context.Background())
+}
+
+// StopAirConditioningContext is like StopAirConditioning but uses ctx for the request.
+func (v *Vehicle) StopAirConditioningContext(ctx context.Context) error {
 	apiURL := v.commandPath("auto_conditioning_stop")
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
+// SeatHeaterRequest is the body of the remote_seat_heater_request command.
+type SeatHeaterRequest struct {
+	Heater int `json:"heater"`
+	Level  int `json:"level"`
+}
+
 // SetSeatHeater sets the specified seat's heater level.
 func (v *Vehicle) SetSeatHeater(heater int, level int) error {
-	url := v.commandPath("remote_seat_heater_request")
-	payload := fmt.Sprintf(`{"heater":%d, "level":%d}`, heater, level)
-	_, err := v.c.post(url, []byte(payload))
-	return err
+	return v.SetSeatHeaterContext(context.Background(), heater, level)
+}
+
+// SetSeatHeaterContext is like SetSeatHeater but uses ctx for the request.
+func (v *Vehicle) SetSeatHeaterContext(ctx context.Context, heater int, level int) error {
+	return v.sendCommandRequest(ctx, "remote_seat_heater_request", &SeatHeaterRequest{Heater: heater, Level: level})
 }
 
 // SetSteeringWheelHeater turns steering wheel heater on or off.
 func (v *Vehicle) SetSteeringWheelHeater(on bool) error {
-	url := v.commandPath("remote_steering_wheel_heater_request")
-	payload := fmt.Sprintf(`{"on":%t}`, on)
-	_, err := v.c.post(url, []byte(payload))
+	return v.SetSteeringWheelHeaterContext(context.Background(), on)
+}
+
+// SetSteeringWheelHeaterContext is like SetSteeringWheelHeater but uses ctx for the request.
+func (v *Vehicle) SetSteeringWheelHeaterContext(ctx context.Context, on bool) error {
+	return v.sendCommandRequest(ctx, "remote_steering_wheel_heater_request", &OnRequest{On: on})
+}
+
+// PreconditioningMaxRequest is the body of the set_preconditioning_max command.
+type PreconditioningMaxRequest struct {
+	On bool `json:"on"`
+}
+
+// SetPreconditioningMax turns max defrost on or off. Turning it on also starts
+// the climate control at its highest setting.
+func (v *Vehicle) SetPreconditioningMax(ctx context.Context, on bool) error {
+	return v.sendCommandRequest(ctx, "set_preconditioning_max", &PreconditioningMaxRequest{On: on})
+}
+
+// ClimateKeeperMode keeps the climate control running after leaving the vehicle.
+type ClimateKeeperMode int
+
+const (
+	ClimateKeeperOff ClimateKeeperMode = iota
+	// ClimateKeeperKeep is the app's "Keep" mode, reported as "on".
+	ClimateKeeperKeep
+	ClimateKeeperDog
+	ClimateKeeperCamp
+)
+
+// String returns the mode as reported in ClimateState.ClimateKeeperMode.
+func (m ClimateKeeperMode) String() string {
+	switch m {
+	case ClimateKeeperOff:
+		return "off"
+	case ClimateKeeperKeep:
+		return "on"
+	case ClimateKeeperDog:
+		return "dog"
+	case ClimateKeeperCamp:
+		return "camp"
+	}
+	return "ClimateKeeperMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ClimateKeeperModeRequest is the body of the set_climate_keeper_mode command.
+type ClimateKeeperModeRequest struct {
+	ClimateKeeperMode ClimateKeeperMode `json:"climate_keeper_mode"`
+}
+
+// SetClimateKeeperMode sets the climate keeper mode, such as dog or camp mode.
+func (v *Vehicle) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) error {
+	if mode < ClimateKeeperOff || mode > ClimateKeeperCamp {
+		return fmt.Errorf("invalid climate keeper mode %d", mode)
+	}
+	return v.sendCommandRequest(ctx, "set_climate_keeper_mode", &ClimateKeeperModeRequest{ClimateKeeperMode: mode})
+}
+
+// BioweaponModeRequest is the body of the set_bioweapon_mode command.
+type BioweaponModeRequest struct {
+	On             bool `json:"on"`
+	ManualOverride bool `json:"manual_override"`
+}
+
+// SetBioweaponMode turns bioweapon defense mode on or off. manualOverride turns it
+// off even when the vehicle enabled it automatically because of poor air quality.
+func (v *Vehicle) SetBioweaponMode(ctx context.Context, on, manualOverride bool) error {
+	return v.sendCommandRequest(ctx, "set_bioweapon_mode", &BioweaponModeRequest{On: on, ManualOverride: manualOverride})
+}
+
+// CabinOverheatProtectionRequest is the body of the set_cabin_overheat_protection command.
+type CabinOverheatProtectionRequest struct {
+	On      bool `json:"on"`
+	FanOnly bool `json:"fan_only"`
+}
+
+// SetCabinOverheatProtection turns cabin overheat protection on or off. With
+// fanOnly the vehicle only runs the fan instead of the air conditioning.
+func (v *Vehicle) SetCabinOverheatProtection(ctx context.Context, on, fanOnly bool) error {
+	return v.sendCommandRequest(ctx, "set_cabin_overheat_protection", &CabinOverheatProtectionRequest{On: on, FanOnly: fanOnly})
+}
+
+// CopTemp is the temperature above which cabin overheat protection cools the cabin.
+type CopTemp int
+
+const (
+	CopTempLow    CopTemp = iota // 30°C
+	CopTempMedium                // 35°C
+	CopTempHigh                  // 40°C
+)
+
+// CopTempRequest is the body of the set_cop_temp command.
+type CopTempRequest struct {
+	CopTemp CopTemp `json:"cop_temp"`
+}
+
+// SetCopTemp sets the activation temperature of cabin overheat protection.
+func (v *Vehicle) SetCopTemp(ctx context.Context, temp CopTemp) error {
+	if temp < CopTempLow || temp > CopTempHigh {
+		return fmt.Errorf("invalid cabin overheat protection temperature %d", temp)
+	}
+	return v.sendCommandRequest(ctx, "set_cop_temp", &CopTempRequest{CopTemp: temp})
+}
+
+// SeatPosition selects a seat with climate control.
+type SeatPosition int
+
+const (
+	SeatFrontLeft SeatPosition = iota + 1
+	SeatFrontRight
+)
+
+// SeatCoolerLevel is the ventilation level of a seat.
+type SeatCoolerLevel int
+
+const (
+	SeatCoolerOff SeatCoolerLevel = iota
+	SeatCoolerLow
+	SeatCoolerMedium
+	SeatCoolerHigh
+)
+
+// SeatCoolerRequest is the body of the remote_seat_cooler_request command.
+type SeatCoolerRequest struct {
+	SeatPosition    SeatPosition    `json:"seat_position"`
+	SeatCoolerLevel SeatCoolerLevel `json:"seat_cooler_level"`
+}
+
+// SetSeatCooler sets the ventilation level of a front seat.
+func (v *Vehicle) SetSeatCooler(ctx context.Context, seat SeatPosition, level SeatCoolerLevel) error {
+	if seat != SeatFrontLeft && seat != SeatFrontRight {
+		return fmt.Errorf("invalid seat position %d", seat)
+	}
+	if level < SeatCoolerOff || level > SeatCoolerHigh {
+		return fmt.Errorf("invalid seat cooler level %d", level)
+	}
+	return v.sendCommandRequest(ctx, "remote_seat_cooler_request", &SeatCoolerRequest{SeatPosition: seat, SeatCoolerLevel: level})
+}
+
+// AutoSeatClimateRequest is the body of the remote_auto_seat_climate_request command.
+type AutoSeatClimateRequest struct {
+	AutoSeatPosition SeatPosition `json:"auto_seat_position"`
+	AutoClimateOn    bool         `json:"auto_climate_on"`
+}
+
+// SetAutoSeatClimate turns automatic heating and cooling of a front seat on or off.
+func (v *Vehicle) SetAutoSeatClimate(ctx context.Context, seat SeatPosition, on bool) error {
+	if seat != SeatFrontLeft && seat != SeatFrontRight {
+		return fmt.Errorf("invalid seat position %d", seat)
+	}
+	return v.sendCommandRequest(ctx, "remote_auto_seat_climate_request", &AutoSeatClimateRequest{AutoSeatPosition: seat, AutoClimateOn: on})
+}
+
+// Sends a command with the request encoded as its JSON body, waking the vehicle
+// first if needed and enabled
+func (v *Vehicle) sendCommandRequest(ctx context.Context, command string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	_, err = v.sendCommand(ctx, v.commandPath(command), body)
 	return err
 }
 
+// RoofState is a position of the panoramic roof.
+type RoofState string
+
+const (
+	RoofOpen    RoofState = "open"
+	RoofClose   RoofState = "close"
+	RoofComfort RoofState = "comfort"
+	RoofVent    RoofState = "vent"
+	// RoofMove moves the roof to the percentage passed to MovePanoRoof.
+	RoofMove RoofState = "move"
+)
+
+// SunRoofRequest is the body of the sun_roof_control command.
+type SunRoofRequest struct {
+	State   RoofState `json:"state"`
+	Percent int       `json:"percent"`
+}
+
 // MovePanoRoof sets the desired state of the panoramic roof. The approximate percent open
 // values for each state are open = 100%, close = 0%, comfort = 80%, vent = %15, move = set %.
-func (v *Vehicle) MovePanoRoof(state string, percent int) error {
-	apiURL := v.commandPath("sun_roof_control")
-	payload := `{"state": "` + state + `", "percent":` + strconv.Itoa(percent) + `}`
-	_, err := v.c.post(apiURL, []byte(payload))
-	return err
+func (v *Vehicle) MovePanoRoof(state RoofState, percent int) error {
+	return v.MovePanoRoofContext(context.Background(), state, percent)
+}
+
+// MovePanoRoofContext is like MovePanoRoof but uses ctx for the request.
+func (v *Vehicle) MovePanoRoofContext(ctx context.Context, state RoofState, percent int) error {
+	switch state {
+	case RoofOpen, RoofClose, RoofComfort, RoofVent, RoofMove:
+	default:
+		return fmt.Errorf("unknown roof state %q", state)
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("roof position %d%% is not between 0%% and 100%%", percent)
+	}
+	return v.sendCommandRequest(ctx, "sun_roof_control", &SunRoofRequest{State: state, Percent: percent})
+}
+
+// WindowCommand is an operation on all windows of the vehicle.
+type WindowCommand string
+
+const (
+	WindowVent  WindowCommand = "vent"
+	WindowClose WindowCommand = "close"
+)
+
+// WindowControlRequest is the body of the window_control command.
+type WindowControlRequest struct {
+	Command WindowCommand `json:"command"`
+	Lat     float64       `json:"lat"`
+	Lon     float64       `json:"lon"`
 }
 
-// Controls the windows. Will vent or close all windows simultaneously. command can be "vent" or "close".
+// Controls the windows. Will vent or close all windows simultaneously.
 // lat and lon values must be near the current location of the car for close operation to succeed.
+// When closing with both lat and lon 0, the current location of the car is used.
 // For vent, the lat and lon values are ignored, and may both be 0 (which has been observed from the app itself).
-func (v *Vehicle) WindowControl(command string, lat, lon float64) error {
-	apiURL := v.commandPath("window_control")
-	payload := fmt.Sprintf(`{"command":"%s", "lat": %f, "lon": %f}`, command, lat, lon)
-	_, err := v.c.post(apiURL, []byte(payload))
-	return err
+func (v *Vehicle) WindowControl(command WindowCommand, lat, lon float64) error {
+	return v.WindowControlContext(context.Background(), command, lat, lon)
+}
+
+// WindowControlContext is like WindowControl but uses ctx for the request.
+func (v *Vehicle) WindowControlContext(ctx context.Context, command WindowCommand, lat, lon float64) error {
+	switch command {
+	case WindowVent:
+	case WindowClose:
+		if lat == 0 && lon == 0 {
+			var err error
+			if lat, lon, err = v.location(ctx); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown window command %q", command)
+	}
+	return v.sendCommandRequest(ctx, "window_control", &WindowControlRequest{Command: command, Lat: lat, Lon: lon})
+}
+
+// VentWindows vents all windows.
+func (v *Vehicle) VentWindows(ctx context.Context) error {
+	return v.WindowControlContext(ctx, WindowVent, 0, 0)
+}
+
+// CloseWindows closes all windows, using the current location of the vehicle.
+func (v *Vehicle) CloseWindows(ctx context.Context) error {
+	return v.WindowControlContext(ctx, WindowClose, 0, 0)
 }
 
 // Start starts the car by turning it on, requires the password to be sent again.
 func (v *Vehicle) Start(password string) error {
+	return v.StartContext(context.Background(), password)
+}
+
+// StartContext is like Start but uses ctx for the request.
+func (v *Vehicle) StartContext(ctx context.Context, password string) error {
 	apiURL := v.commandPath("remote_start_drive?password=" + password)
-	_, err := v.sendCommand(apiURL, nil)
+	_, err := v.sendCommand(ctx, apiURL, nil)
 	return err
 }
 
+// Trunk selects the front or rear trunk.
+type Trunk string
+
+const (
+	TrunkFront Trunk = "front"
+	TrunkRear  Trunk = "rear"
+)
+
+// TrunkRequest is the body of the actuate_trunk command.
+type TrunkRequest struct {
+	WhichTrunk Trunk `json:"which_trunk"`
+}
+
 // OpenTrunk opens the trunk, where values may be 'front' or 'rear'.
-func (v *Vehicle) OpenTrunk(trunk string) error {
-	apiURL := v.commandPath("actuate_trunk")
-	payload := `{"which_trunk": "` + trunk + `"}`
-	_, err := v.c.post(apiURL, []byte(payload))
-	return err
+func (v *Vehicle) OpenTrunk(trunk Trunk) error {
+	return v.OpenTrunkContext(context.Background(), trunk)
+}
+
+// OpenTrunkContext is like OpenTrunk but uses ctx for the request.
+func (v *Vehicle) OpenTrunkContext(ctx context.Context, trunk Trunk) error {
+	if trunk != TrunkFront && trunk != TrunkRear {
+		return fmt.Errorf("unknown trunk %q", trunk)
+	}
+	return v.sendCommandRequest(ctx, "actuate_trunk", &TrunkRequest{WhichTrunk: trunk})
 }
 
-// Sends a command to the vehicle
-func (v *Vehicle) sendCommand(url string, reqBody []byte) ([]byte, error) {
-	body, err := v.c.post(url, reqBody)
+// CloseTrunk closes the rear trunk of vehicles with a powered liftgate. The
+// front trunk cannot be closed remotely. Nothing is sent if the trunk is closed.
+func (v *Vehicle) CloseTrunk(ctx context.Context, trunk Trunk) error {
+	if trunk != TrunkRear {
+		return fmt.Errorf("the %s trunk cannot be closed remotely", trunk)
+	}
+	data, err := v.DataFor(ctx, DataEndpointVehicleConfig, DataEndpointVehicleState, DataEndpointClosuresState)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	config := data.Response.VehicleConfig
+	var rearTrunk int
+	switch {
+	case config == nil:
+		return errors.New("vehicle did not report its configuration")
+	case data.Response.ClosuresState != nil:
+		rearTrunk = data.Response.ClosuresState.RearTrunk
+	case data.Response.VehicleState != nil:
+		rearTrunk = data.Response.VehicleState.RearTrunk
+	default:
+		return errors.New("vehicle did not report its trunk state")
+	}
+	if !config.Plg || !config.CanActuateTrunks {
+		return errors.New("vehicle has no powered liftgate")
+	}
+	if rearTrunk == 0 {
+		return nil
+	}
+	// actuate_trunk toggles a powered liftgate
+	return v.sendCommandRequest(ctx, "actuate_trunk", &TrunkRequest{WhichTrunk: trunk})
+}
+
+// BoomboxSound is a sound played through the external speaker.
+type BoomboxSound int
+
+const (
+	BoomboxFart       BoomboxSound = 0
+	BoomboxLocatePing BoomboxSound = 2000
+)
+
+// RemoteBoomboxRequest is the body of the remote_boombox command.
+type RemoteBoomboxRequest struct {
+	Sound BoomboxSound `json:"sound"`
+}
+
+// RemoteBoombox plays a sound through the external speaker of the vehicle.
+func (v *Vehicle) RemoteBoombox(ctx context.Context, sound BoomboxSound) error {
+	return v.sendCommandRequest(ctx, "remote_boombox", &RemoteBoomboxRequest{Sound: sound})
+}
+
+// GuestModeRequest is the body of the guest_mode command.
+type GuestModeRequest struct {
+	Enable bool `json:"enable"`
+}
+
+// SetGuestMode turns guest mode on or off, which limits what the driver can
+// change and hides the owner's data.
+func (v *Vehicle) SetGuestMode(ctx context.Context, on bool) error {
+	return v.sendCommandRequest(ctx, "guest_mode", &GuestModeRequest{Enable: on})
+}
+
+// PinToDriveRequest is the body of the set_pin_to_drive command.
+type PinToDriveRequest struct {
+	On       bool   `json:"on"`
+	Password string `json:"password"`
+}
+
+// SetPinToDrive turns PIN to Drive on or off. The 4 digit password is the PIN
+// to set, or the current PIN when turning it off.
+func (v *Vehicle) SetPinToDrive(ctx context.Context, on bool, password string) error {
+	if err := validatePIN(password); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, "set_pin_to_drive", &PinToDriveRequest{On: on, Password: password})
+}
+
+// ResetPinToDrivePIN removes the PIN to Drive PIN.
+func (v *Vehicle) ResetPinToDrivePIN(ctx context.Context) error {
+	_, err := v.sendCommand(ctx, v.commandPath("reset_pin_to_drive_pin"), nil)
+	return err
+}
+
+// EraseUserData erases the user data of the vehicle, such as its address book,
+// navigation history and paired phones. The vehicle must be parked.
+func (v *Vehicle) EraseUserData(ctx context.Context) error {
+	_, err := v.sendCommand(ctx, v.commandPath("erase_user_data"), nil)
+	return err
+}
+
+// VehicleNameRequest is the body of the set_vehicle_name command.
+type VehicleNameRequest struct {
+	VehicleName string `json:"vehicle_name"`
+}
+
+// SetVehicleName renames the vehicle.
+func (v *Vehicle) SetVehicleName(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New("vehicle name is empty")
 	}
-	if len(body) > 0 {
-		response := &CommandResponse{}
-		if err := json.Unmarshal(body, response); err != nil {
+	return v.sendCommandRequest(ctx, "set_vehicle_name", &VehicleNameRequest{VehicleName: name})
+}
+
+// Sends a command to the vehicle, waking it first if needed and enabled
+func (v *Vehicle) sendCommand(ctx context.Context, url string, reqBody []byte) ([]byte, error) {
+	var body []byte
+	err := v.withWake(ctx, func() (err error) {
+		body, err = v.postCommand(ctx, url, reqBody)
+		return err
+	})
+	return body, err
+}
+
+// Posts a command to the vehicle and checks the result. Commands for vehicles
+// that require signing are sent through the vehicle command protocol instead.
+func (v *Vehicle) postCommand(ctx context.Context, url string, reqBody []byte) ([]byte, error) {
+	if domain, payload, ok, err := v.signedEquivalent(url, reqBody); ok {
+		if err != nil {
 			return nil, err
 		}
-		if !response.Response.Result && response.Response.Reason != "" {
-			return nil, errors.New(response.Response.Reason)
-		}
+		return v.SignedCommand(ctx, domain, payload)
+	}
+	body, err := v.c.post(ctx, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := commandError(url, body); err != nil {
+		return nil, err
 	}
 	return body, nil
 }
@@ -1,6 +1,9 @@
 package tesla
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -11,8 +14,14 @@ var (
 	WakeupResponseJSON   = `{"response":{"color":null,"display_name":"Macak","id":900,"option_codes":"MS04,RENA,AU01,BC0R,BP01,BR01,BS00,CDM0,CH00,PBSB,CW02,DA02,DCF0,DRLH,DSH7,DV4W,FG02,HP00,IDPB,IX01,LP01,ME02,MI00,PA00,PF01,PI01,PK00,PS01,PX00,PX4D,QNEB,RFP2,SC01,SP00,SR01,SU01,TM00,TP03,TR01,UTAB,WTSG,WTX0,X001,X003,X007,X011,X013,X019,X024,X027,X028,X031,X037,X040,YF01,COUS","user_id":789,"vehicle_id":456,"vin":"abc123","tokens":["1","2"],"state":"online","id_s":"123","remote_start_enabled":true,"calendar_enabled":true,"notifications_enabled":true,"backseat_token":null,"backseat_token_updated_at":null}}`
 	ChargeAlreadySetJSON = `{"response":{"reason":"already_standard","result":false}}`
 	ChargedJSON          = `{"response":{"reason":"complete","result":false}}`
+	// Current firmware reports the trunks only when closures_state is requested.
+	LiftgateJSON       = `{"response":{"vehicle_config":{"can_actuate_trunks":true,"plg":true},"vehicle_state":{"locked":true}}}`
+	OpenLiftgateJSON   = `{"response":{"vehicle_config":{"can_actuate_trunks":true,"plg":true},"vehicle_state":{"locked":true},"closures_state":{"rt":1}}}`
+	ClosedLiftgateJSON = `{"response":{"vehicle_config":{"can_actuate_trunks":true,"plg":true},"vehicle_state":{"locked":true},"closures_state":{"rt":0}}}`
 )
 
+var liftgateActuations int32
+
 func TestCommandsSpec(t *testing.T) {
 	ts := serveHTTP(t)
 	defer ts.Close()
@@ -44,6 +53,44 @@ func TestCommandsSpec(t *testing.T) {
 		So(err, ShouldBeNil)
 	})
 
+	Convey("Should turn off sentry mode", t, func() {
+		err := vehicle.SetSentryMode(context.Background(), false)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Should control the windows", t, func() {
+		ctx := context.Background()
+		So(vehicle.VentWindows(ctx), ShouldBeNil)
+		So(vehicle.CloseWindows(ctx), ShouldBeNil)
+		So(vehicle.WindowControl(WindowClose, 35.1, 20.2), ShouldBeNil)
+		So(vehicle.WindowControl("open", 0, 0), ShouldNotBeNil)
+	})
+
+	Convey("Should validate trunks and roof states", t, func() {
+		So(vehicle.OpenTrunk("side"), ShouldNotBeNil)
+		So(vehicle.CloseTrunk(context.Background(), TrunkFront), ShouldNotBeNil)
+		So(vehicle.MovePanoRoof("tilt", 0), ShouldNotBeNil)
+		So(vehicle.MovePanoRoof(RoofMove, 101), ShouldNotBeNil)
+	})
+
+	Convey("Should close an open powered liftgate", t, func() {
+		ctx := context.Background()
+		open := &Vehicle{ID: 5678, c: client}
+		So(open.CloseTrunk(ctx, TrunkRear), ShouldBeNil)
+		So(atomic.LoadInt32(&liftgateActuations), ShouldEqual, 1)
+
+		closed := &Vehicle{ID: 5679, c: client}
+		So(closed.CloseTrunk(ctx, TrunkRear), ShouldBeNil)
+		So(vehicle.CloseTrunk(ctx, TrunkRear), ShouldNotBeNil)
+	})
+
+	Convey("Should send typed request bodies", t, func() {
+		So(vehicle.SetSeatHeater(0, 3), ShouldBeNil)
+		So(vehicle.SetSteeringWheelHeater(true), ShouldBeNil)
+		So(vehicle.WindowControl("vent", 0, 0), ShouldBeNil)
+		So(vehicle.OpenTrunk("rear"), ShouldBeNil)
+	})
+
 	Convey("Should toggle the garage door based on Homelink", t, func() {
 		err := vehicle.TriggerHomelink()
 		So(err, ShouldBeNil)
@@ -89,6 +136,17 @@ func TestCommandsSpec(t *testing.T) {
 		So(err, ShouldBeNil)
 	})
 
+	Convey("Should reject charge settings outside the vehicle's limits", t, func() {
+		So(vehicle.SetChargeLimit(49), ShouldNotBeNil)
+		So(vehicle.SetChargeLimit(101), ShouldNotBeNil)
+		So(vehicle.SetChargingAmps(41), ShouldNotBeNil)
+		So(vehicle.SetChargingAmps(-1), ShouldNotBeNil)
+	})
+
+	Convey("Should close the charge port", t, func() {
+		So(vehicle.CloseChargePort(), ShouldBeNil)
+	})
+
 	Convey("Should attempt to charge the car", t, func() {
 		err := vehicle.StartCharging()
 		So(err.Error(), ShouldEqual, "complete")
@@ -152,4 +210,42 @@ func TestCommandsSpec(t *testing.T) {
 			So(err, ShouldBeNil)
 		})
 	})
+
+	Convey("Should control the climate", t, func() {
+		ctx := context.Background()
+		So(vehicle.SetPreconditioningMax(ctx, true), ShouldBeNil)
+		So(vehicle.SetClimateKeeperMode(ctx, ClimateKeeperDog), ShouldBeNil)
+		So(vehicle.SetBioweaponMode(ctx, false, true), ShouldBeNil)
+		So(vehicle.SetCabinOverheatProtection(ctx, true, true), ShouldBeNil)
+		So(vehicle.SetCopTemp(ctx, CopTempMedium), ShouldBeNil)
+		So(vehicle.SetSeatCooler(ctx, SeatFrontRight, SeatCoolerHigh), ShouldBeNil)
+		So(vehicle.SetAutoSeatClimate(ctx, SeatFrontLeft, true), ShouldBeNil)
+		So(ClimateKeeperCamp.String(), ShouldEqual, "camp")
+
+		Convey("Should reject invalid settings", func() {
+			So(vehicle.SetClimateKeeperMode(ctx, ClimateKeeperMode(4)), ShouldNotBeNil)
+			So(vehicle.SetCopTemp(ctx, CopTemp(-1)), ShouldNotBeNil)
+			So(vehicle.SetSeatCooler(ctx, SeatPosition(0), SeatCoolerLow), ShouldNotBeNil)
+			So(vehicle.SetSeatCooler(ctx, SeatFrontLeft, SeatCoolerLevel(4)), ShouldNotBeNil)
+			So(vehicle.SetAutoSeatClimate(ctx, SeatPosition(3), true), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Should send security commands", t, func() {
+		ctx := context.Background()
+		So(vehicle.RemoteBoombox(ctx, BoomboxLocatePing), ShouldBeNil)
+		So(vehicle.SetGuestMode(ctx, true), ShouldBeNil)
+		So(vehicle.SetPinToDrive(ctx, true, "1234"), ShouldBeNil)
+		So(vehicle.SetPinToDrive(ctx, true, "12"), ShouldNotBeNil)
+		So(vehicle.ResetPinToDrivePIN(ctx), ShouldBeNil)
+		So(vehicle.SetVehicleName(ctx, "Macak"), ShouldBeNil)
+		So(vehicle.SetVehicleName(ctx, ""), ShouldNotBeNil)
+
+		Convey("Should return the reason of failed commands", func() {
+			err := vehicle.EraseUserData(ctx)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "not_parked")
+			So(errors.Is(err, ErrCommandFailed), ShouldBeTrue)
+		})
+	})
 }
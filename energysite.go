@@ -1,9 +1,11 @@
 package tesla
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -12,15 +14,120 @@ import (
 
 // this represents site_info endpoint
 type EnergySite struct {
-	ID                   string `json:"id"`
-	SiteName             string `json:"site_name"`
-	BackupReservePercent int64  `json:"backup_reserve_percent,omitempty"`
-	DefaultRealMode      string `json:"default_real_mode,omitempty"`
+	ID                                   string                 `json:"id"`
+	SiteName                             string                 `json:"site_name"`
+	BackupReservePercent                 int64                  `json:"backup_reserve_percent,omitempty"`
+	DefaultRealMode                      string                 `json:"default_real_mode,omitempty"`
+	InstallationDate                     time.Time              `json:"installation_date"`
+	InstallationTimeZone                 string                 `json:"installation_time_zone"`
+	UserSettings                         EnergySiteUserSettings `json:"user_settings"`
+	Components                           EnergySiteComponents   `json:"components"`
+	Version                              string                 `json:"version"`
+	BatteryCount                         int                    `json:"battery_count"`
+	NameplatePower                       int64                  `json:"nameplate_power"`
+	NameplateEnergy                      int64                  `json:"nameplate_energy"`
+	OffGridVehicleChargingReservePercent int64                  `json:"off_grid_vehicle_charging_reserve_percent"`
+	VPPBackupReservePercent              int64                  `json:"vpp_backup_reserve_percent"`
+	MaxSiteMeterPowerAC                  int64                  `json:"max_site_meter_power_ac"`
+	MinSiteMeterPowerAC                  int64                  `json:"min_site_meter_power_ac"`
+	Geolocation                          EnergySiteGeolocation  `json:"geolocation"`
+	Address                              EnergySiteAddress      `json:"address"`
+	Utility                              string                 `json:"utility"`
+	TariffID                             string                 `json:"tariff_id"`
+	TariffContent                        *Tariff                `json:"tariff_content"`
 
 	productId int64
 	c         *Client
 }
 
+// Location returns the time zone the site is installed in.
+func (s *EnergySite) Location() (*time.Location, error) {
+	if s.InstallationTimeZone == "" {
+		return nil, errors.New("energy site has no installation time zone")
+	}
+	return time.LoadLocation(s.InstallationTimeZone)
+}
+
+// EnergySiteUserSettings contains the settings the owner made in the app.
+type EnergySiteUserSettings struct {
+	GoOffGridTestBannerEnabled       bool `json:"go_off_grid_test_banner_enabled"`
+	StormModeEnabled                 bool `json:"storm_mode_enabled"`
+	PowerwallOnboardingSettingsSet   bool `json:"powerwall_onboarding_settings_set"`
+	PowerwallTeslaElectricInterested bool `json:"powerwall_tesla_electric_interested_in"`
+	VPPTourEnabled                   bool `json:"vpp_tour_enabled"`
+	SyncGridAlertEnabled             bool `json:"sync_grid_alert_enabled"`
+	BreakerAlertEnabled              bool `json:"breaker_alert_enabled"`
+}
+
+// EnergySiteComponents describes the equipment installed at the site and the
+// features it supports.
+type EnergySiteComponents struct {
+	Solar                                  bool                `json:"solar"`
+	SolarType                              string              `json:"solar_type"`
+	Battery                                bool                `json:"battery"`
+	BatteryType                            string              `json:"battery_type"`
+	Grid                                   bool                `json:"grid"`
+	Backup                                 bool                `json:"backup"`
+	Gateway                                string              `json:"gateway"`
+	LoadMeter                              bool                `json:"load_meter"`
+	TOUCapable                             bool                `json:"tou_capable"`
+	StormModeCapable                       bool                `json:"storm_mode_capable"`
+	OffGridVehicleChargingReserveSupported bool                `json:"off_grid_vehicle_charging_reserve_supported"`
+	Configurable                           bool                `json:"configurable"`
+	GridServicesEnabled                    bool                `json:"grid_services_enabled"`
+	DisallowChargeFromGridWithSolar        bool                `json:"disallow_charge_from_grid_with_solar_installed"`
+	CustomerPreferredExportRule            ExportRule          `json:"customer_preferred_export_rule"`
+	NetMeterMode                           string              `json:"net_meter_mode"`
+	SystemAlertsEnabled                    bool                `json:"system_alerts_enabled"`
+	Gateways                               []EnergySiteDevice  `json:"gateways"`
+	Batteries                              []EnergySiteBattery `json:"batteries"`
+	WallConnectors                         []EnergySiteDevice  `json:"wall_connectors"`
+}
+
+// EnergySiteDevice is a gateway, wall connector or other device at the site.
+type EnergySiteDevice struct {
+	DeviceID        string    `json:"device_id"`
+	DIN             string    `json:"din"`
+	SerialNumber    string    `json:"serial_number"`
+	PartNumber      string    `json:"part_number"`
+	PartType        int       `json:"part_type"`
+	PartName        string    `json:"part_name"`
+	IsActive        bool      `json:"is_active"`
+	SiteID          string    `json:"site_id"`
+	FirmwareVersion string    `json:"firmware_version"`
+	UpdatedDatetime time.Time `json:"updated_datetime"`
+}
+
+// EnergySiteBattery is a battery at the site with its nameplate ratings.
+type EnergySiteBattery struct {
+	DeviceID                   string `json:"device_id"`
+	DIN                        string `json:"din"`
+	SerialNumber               string `json:"serial_number"`
+	PartNumber                 string `json:"part_number"`
+	PartType                   int    `json:"part_type"`
+	PartName                   string `json:"part_name"`
+	NameplateMaxChargePower    int64  `json:"nameplate_max_charge_power"`
+	NameplateMaxDischargePower int64  `json:"nameplate_max_discharge_power"`
+	NameplateEnergy            int64  `json:"nameplate_energy"`
+}
+
+// EnergySiteGeolocation is the location of the site.
+type EnergySiteGeolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Source    string  `json:"source"`
+}
+
+// EnergySiteAddress is the postal address of the site.
+type EnergySiteAddress struct {
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	Zip          string `json:"zip"`
+	Country      string `json:"country"`
+}
+
 type EnergySiteStatus struct {
 	ResourceType      string  `json:"resource_type"`
 	SiteName          string  `json:"site_name"`
@@ -85,8 +192,13 @@ type SiteCommandResponse struct {
 
 // return fetches the energy site for the given product ID
 func (c *Client) EnergySite(productID int64) (*EnergySite, error) {
+	return c.EnergySiteContext(context.Background(), productID)
+}
+
+// EnergySiteContext is like EnergySite but uses ctx for the request.
+func (c *Client) EnergySiteContext(ctx context.Context, productID int64) (*EnergySite, error) {
 	siteInfoResponse := &SiteInfoResponse{}
-	if err := c.getJSON(c.baseURL+"/energy_sites/"+strconv.FormatInt(productID, 10)+"/site_info", siteInfoResponse); err != nil {
+	if err := c.getJSON(ctx, c.baseURL+"/energy_sites/"+strconv.FormatInt(productID, 10)+"/site_info", siteInfoResponse); err != nil {
 		return nil, err
 	}
 	siteInfoResponse.Response.c = c
@@ -95,14 +207,86 @@ func (c *Client) EnergySite(productID int64) (*EnergySite, error) {
 }
 
 func (s *EnergySite) EnergySiteStatus() (*EnergySiteStatus, error) {
+	return s.EnergySiteStatusContext(context.Background())
+}
+
+// EnergySiteStatusContext is like EnergySiteStatus but uses ctx for the request.
+func (s *EnergySite) EnergySiteStatusContext(ctx context.Context) (*EnergySiteStatus, error) {
 	siteStatusResponse := &SiteStatusResponse{}
-	if err := s.c.getJSON(s.statusPath(), siteStatusResponse); err != nil {
+	if err := s.c.getJSON(ctx, s.statusPath(), siteStatusResponse); err != nil {
 		return nil, err
 	}
 	siteStatusResponse.Response.c = s.c
 	return siteStatusResponse.Response, nil
 }
 
+// GridStatus is whether the site is connected to the grid.
+type GridStatus string
+
+const (
+	GridStatusActive GridStatus = "Active"
+	// GridStatusInactive means the site is islanded from the grid.
+	GridStatusInactive GridStatus = "Inactive"
+)
+
+// IslandStatus is why the site is connected to or islanded from the grid.
+type IslandStatus string
+
+const (
+	IslandStatusOnGrid               IslandStatus = "on_grid"
+	IslandStatusOffGrid              IslandStatus = "off_grid"
+	IslandStatusOffGridIntentional   IslandStatus = "off_grid_intentional"
+	IslandStatusOffGridUnintentional IslandStatus = "off_grid_unintentional"
+	IslandStatusUnknown              IslandStatus = "island_status_unknown"
+)
+
+// EnergySiteLiveStatus contains the instantaneous power flows of the site, in
+// watts. Positive battery power discharges the battery and positive grid power
+// imports from the grid.
+type EnergySiteLiveStatus struct {
+	SolarPower         float64                   `json:"solar_power"`
+	BatteryPower       float64                   `json:"battery_power"`
+	GridPower          float64                   `json:"grid_power"`
+	LoadPower          float64                   `json:"load_power"`
+	GeneratorPower     float64                   `json:"generator_power"`
+	GridServicesPower  float64                   `json:"grid_services_power"`
+	GridServicesActive bool                      `json:"grid_services_active"`
+	GridStatus         GridStatus                `json:"grid_status"`
+	IslandStatus       IslandStatus              `json:"island_status"`
+	StormModeActive    bool                      `json:"storm_mode_active"`
+	BackupCapable      bool                      `json:"backup_capable"`
+	EnergyLeft         float64                   `json:"energy_left"`
+	TotalPackEnergy    float64                   `json:"total_pack_energy"`
+	PercentageCharged  float64                   `json:"percentage_charged"`
+	Timestamp          time.Time                 `json:"timestamp"`
+	WallConnectors     []WallConnectorLiveStatus `json:"wall_connectors"`
+}
+
+// WallConnectorLiveStatus is the state of a wall connector powered by the site.
+type WallConnectorLiveStatus struct {
+	DIN                     string  `json:"din"`
+	WallConnectorState      int     `json:"wall_connector_state"`
+	WallConnectorFaultState int     `json:"wall_connector_fault_state"`
+	WallConnectorPower      float64 `json:"wall_connector_power"`
+}
+
+// SiteLiveStatusResponse contains the live status from the Tesla API.
+type SiteLiveStatusResponse struct {
+	Response *EnergySiteLiveStatus `json:"response"`
+}
+
+// LiveStatus fetches the current power flows of the site.
+func (s *EnergySite) LiveStatus(ctx context.Context) (*EnergySiteLiveStatus, error) {
+	liveStatusResponse := &SiteLiveStatusResponse{}
+	if err := s.c.getJSON(ctx, s.basePath()+"/live_status", liveStatusResponse); err != nil {
+		return nil, err
+	}
+	if liveStatusResponse.Response == nil {
+		return nil, errors.New("live status response is empty")
+	}
+	return liveStatusResponse.Response, nil
+}
+
 type HistoryPeriod string
 
 const (
@@ -113,8 +297,13 @@ const (
 )
 
 func (s *EnergySite) EnergySiteHistory(period HistoryPeriod) (*EnergySiteHistory, error) {
+	return s.EnergySiteHistoryContext(context.Background(), period)
+}
+
+// EnergySiteHistoryContext is like EnergySiteHistory but uses ctx for the request.
+func (s *EnergySite) EnergySiteHistoryContext(ctx context.Context, period HistoryPeriod) (*EnergySiteHistory, error) {
 	historyResponse := &SiteHistoryResponse{}
-	if err := s.c.getJSON(s.historyPath(period), historyResponse); err != nil {
+	if err := s.c.getJSON(ctx, s.historyPath(HistoryKindEnergy, period), historyResponse); err != nil {
 		return nil, err
 	}
 	historyResponse.Response.c = s.c
@@ -129,18 +318,140 @@ func (s *EnergySite) statusPath() string {
 	return strings.Join([]string{s.basePath(), "site_status"}, "/")
 }
 
-func (s *EnergySite) historyPath(period HistoryPeriod) string {
+func (s *EnergySite) historyPath(kind HistoryKind, period HistoryPeriod) string {
 	v := url.Values{}
-	v.Set("kind", "energy")
+	v.Set("kind", string(kind))
 	v.Set("period", string(period))
 
 	return strings.Join([]string{s.basePath(), "history"}, "/") + fmt.Sprintf("?%s", v.Encode())
 }
 
 func (s *EnergySite) SetBatteryReserve(percent uint64) error {
-	url := s.basePath() + "/backup"
-	payload := fmt.Sprintf(`{"backup_reserve_percent":%d}`, percent)
-	body, err := s.sendCommand(url, []byte(payload))
+	return s.SetBatteryReserveContext(context.Background(), percent)
+}
+
+// SetBatteryReserveContext is like SetBatteryReserve but uses ctx for the request.
+func (s *EnergySite) SetBatteryReserveContext(ctx context.Context, percent uint64) error {
+	if err := s.siteCommand(ctx, "batteryReserve", "backup", &batteryReserveRequest{BackupReservePercent: percent}); err != nil {
+		return err
+	}
+	s.BackupReservePercent = int64(percent)
+	return nil
+}
+
+type batteryReserveRequest struct {
+	BackupReservePercent uint64 `json:"backup_reserve_percent"`
+}
+
+// OperationMode is how a Powerwall uses its battery.
+type OperationMode string
+
+const (
+	// OperationModeSelfConsumption stores solar energy to power the home.
+	OperationModeSelfConsumption OperationMode = "self_consumption"
+	// OperationModeAutonomous charges and discharges to save money on time-of-use rates.
+	OperationModeAutonomous OperationMode = "autonomous"
+	// OperationModeBackup keeps the battery full for outages.
+	OperationModeBackup OperationMode = "backup"
+)
+
+// OperationModeRequest is the body of the operation command.
+type OperationModeRequest struct {
+	DefaultRealMode OperationMode `json:"default_real_mode"`
+}
+
+// SetOperationMode sets the operation mode of the site.
+func (s *EnergySite) SetOperationMode(ctx context.Context, mode OperationMode) error {
+	switch mode {
+	case OperationModeSelfConsumption, OperationModeAutonomous, OperationModeBackup:
+	default:
+		return fmt.Errorf("unknown operation mode %q", mode)
+	}
+	if err := s.siteCommand(ctx, "operationMode", "operation", &OperationModeRequest{DefaultRealMode: mode}); err != nil {
+		return err
+	}
+	s.DefaultRealMode = string(mode)
+	return nil
+}
+
+// StormWatchRequest is the body of the storm_mode command.
+type StormWatchRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetStormWatch turns Storm Watch on or off, which charges the battery fully
+// ahead of severe weather.
+func (s *EnergySite) SetStormWatch(ctx context.Context, enabled bool) error {
+	if err := s.siteCommand(ctx, "stormWatch", "storm_mode", &StormWatchRequest{Enabled: enabled}); err != nil {
+		return err
+	}
+	s.UserSettings.StormModeEnabled = enabled
+	return nil
+}
+
+// ExportRule selects which energy the site may export to the grid.
+type ExportRule string
+
+const (
+	ExportPVOnly    ExportRule = "pv_only"
+	ExportBatteryOK ExportRule = "battery_ok"
+	ExportNever     ExportRule = "never"
+)
+
+// GridImportExportRequest is the body of the grid_import_export command.
+type GridImportExportRequest struct {
+	DisallowChargeFromGridWithSolarInstalled bool       `json:"disallow_charge_from_grid_with_solar_installed"`
+	CustomerPreferredExportRule              ExportRule `json:"customer_preferred_export_rule"`
+}
+
+// SetGridImportExport sets whether the battery may charge from the grid and
+// which energy may be exported to it.
+func (s *EnergySite) SetGridImportExport(ctx context.Context, allowChargingFromGrid bool, exportRule ExportRule) error {
+	switch exportRule {
+	case ExportPVOnly, ExportBatteryOK, ExportNever:
+	default:
+		return fmt.Errorf("unknown export rule %q", exportRule)
+	}
+	if err := s.siteCommand(ctx, "gridImportExport", "grid_import_export", &GridImportExportRequest{
+		DisallowChargeFromGridWithSolarInstalled: !allowChargingFromGrid,
+		CustomerPreferredExportRule:              exportRule,
+	}); err != nil {
+		return err
+	}
+	s.Components.DisallowChargeFromGridWithSolar = !allowChargingFromGrid
+	s.Components.CustomerPreferredExportRule = exportRule
+	return nil
+}
+
+// OffGridVehicleChargingReserveRequest is the body of the off_grid_vehicle_charging_reserve command.
+type OffGridVehicleChargingReserveRequest struct {
+	OffGridVehicleChargingReservePercent uint64 `json:"off_grid_vehicle_charging_reserve_percent"`
+}
+
+// SetOffGridVehicleChargingReserve sets the battery level below which vehicles
+// stop charging from the site during a grid outage.
+func (s *EnergySite) SetOffGridVehicleChargingReserve(ctx context.Context, percent uint64) error {
+	if percent > 100 {
+		return fmt.Errorf("reserve %d%% is not between 0%% and 100%%", percent)
+	}
+	if err := s.siteCommand(ctx, "offGridVehicleChargingReserve", "off_grid_vehicle_charging_reserve", &OffGridVehicleChargingReserveRequest{
+		OffGridVehicleChargingReservePercent: percent,
+	}); err != nil {
+		return err
+	}
+	s.OffGridVehicleChargingReservePercent = int64(percent)
+	return nil
+}
+
+// Posts a command to the site and checks the code of the SiteCommandResponse.
+// Callers update the matching fields of s once the command succeeded.
+func (s *EnergySite) siteCommand(ctx context.Context, name, command string, request interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	url := s.basePath() + "/" + command
+	body, err := s.sendCommand(ctx, url, payload)
 	if err != nil {
 		return err
 	}
@@ -151,26 +462,25 @@ func (s *EnergySite) SetBatteryReserve(percent uint64) error {
 	}
 
 	if response.Response.Code != 201 {
-		return fmt.Errorf("batteryReserve failed: %s", response.Response.Message)
+		return fmt.Errorf("%s failed: %w", name, &APIError{
+			StatusCode: http.StatusOK,
+			Endpoint:   endpointPath(url),
+			Reason:     response.Response.Message,
+			Body:       body,
+		})
 	}
 
 	return nil
 }
 
 // Sends a command to the vehicle
-func (s *EnergySite) sendCommand(url string, reqBody []byte) ([]byte, error) {
-	body, err := s.c.post(url, reqBody)
+func (s *EnergySite) sendCommand(ctx context.Context, url string, reqBody []byte) ([]byte, error) {
+	body, err := s.c.post(ctx, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
-	if len(body) > 0 {
-		response := &CommandResponse{}
-		if err := json.Unmarshal(body, response); err != nil {
-			return nil, err
-		}
-		if !response.Response.Result && response.Response.Reason != "" {
-			return nil, errors.New(response.Response.Reason)
-		}
+	if err := commandError(url, body); err != nil {
+		return nil, err
 	}
 	return body, nil
 }
@@ -0,0 +1,180 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HistoryKind selects the data of a history request.
+type HistoryKind string
+
+const (
+	HistoryKindEnergy          HistoryKind = "energy"
+	HistoryKindPower           HistoryKind = "power"
+	HistoryKindBackup          HistoryKind = "backup"
+	HistoryKindSelfConsumption HistoryKind = "self_consumption"
+)
+
+// HistoryQuery selects the time range of a history request.
+type HistoryQuery struct {
+	// Period is the length of the range. Without an EndDate it defaults to
+	// HistoryPeriodDay.
+	Period HistoryPeriod
+	// EndDate selects the period ending at this time through the
+	// calendar_history endpoint. When zero, the current period is returned.
+	EndDate time.Time
+	// StartDate optionally starts a custom range ending at EndDate.
+	StartDate time.Time
+	// TimeZone the dates are interpreted in, so it needs an EndDate. Defaults
+	// to the installation time zone of the site. It must be a named zone such
+	// as America/Los_Angeles, not time.Local.
+	TimeZone *time.Location
+}
+
+// PowerHistory contains the power flows of the site in 5 minute intervals.
+type PowerHistory struct {
+	SerialNumber         string                   `json:"serial_number"`
+	InstallationTimeZone string                   `json:"installation_time_zone"`
+	TimeSeries           []PowerHistoryTimeSeries `json:"time_series"`
+}
+
+// PowerHistoryTimeSeries is the average power in watts over an interval.
+type PowerHistoryTimeSeries struct {
+	Timestamp         time.Time `json:"timestamp"`
+	SolarPower        float64   `json:"solar_power"`
+	BatteryPower      float64   `json:"battery_power"`
+	GridPower         float64   `json:"grid_power"`
+	GridServicesPower float64   `json:"grid_services_power"`
+	GeneratorPower    float64   `json:"generator_power"`
+}
+
+// BackupHistory contains the grid outages the site powered through.
+type BackupHistory struct {
+	Events      []BackupEvent `json:"events"`
+	TotalEvents int           `json:"total_events"`
+}
+
+// BackupEvent is a grid outage.
+type BackupEvent struct {
+	Timestamp time.Time
+	Duration  time.Duration
+}
+
+// UnmarshalJSON decodes the event, whose duration is in milliseconds.
+func (e *BackupEvent) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Timestamp time.Time `json:"timestamp"`
+		Duration  int64     `json:"duration"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	e.Timestamp = raw.Timestamp
+	e.Duration = time.Duration(raw.Duration) * time.Millisecond
+	return nil
+}
+
+// SelfConsumptionHistory contains the share of the consumption covered by
+// solar and battery.
+type SelfConsumptionHistory struct {
+	Period     HistoryPeriod                      `json:"period"`
+	TimeZone   string                             `json:"timezone"`
+	TimeSeries []SelfConsumptionHistoryTimeSeries `json:"time_series"`
+}
+
+// SelfConsumptionHistoryTimeSeries contains the percentages for one interval.
+type SelfConsumptionHistoryTimeSeries struct {
+	Timestamp time.Time `json:"timestamp"`
+	Solar     float64   `json:"solar"`
+	Battery   float64   `json:"battery"`
+}
+
+// EnergyHistory fetches the energy totals of the site for the queried range.
+func (s *EnergySite) EnergyHistory(ctx context.Context, q HistoryQuery) (*EnergySiteHistory, error) {
+	history := &EnergySiteHistory{}
+	if err := s.history(ctx, HistoryKindEnergy, q, history); err != nil {
+		return nil, err
+	}
+	history.c = s.c
+	return history, nil
+}
+
+// PowerHistory fetches the power flows of the site for the queried range.
+func (s *EnergySite) PowerHistory(ctx context.Context, q HistoryQuery) (*PowerHistory, error) {
+	history := &PowerHistory{}
+	if err := s.history(ctx, HistoryKindPower, q, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// BackupHistory fetches the grid outages of the queried range.
+func (s *EnergySite) BackupHistory(ctx context.Context, q HistoryQuery) (*BackupHistory, error) {
+	history := &BackupHistory{}
+	if err := s.history(ctx, HistoryKindBackup, q, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// SelfConsumptionHistory fetches the self-consumption of the queried range.
+func (s *EnergySite) SelfConsumptionHistory(ctx context.Context, q HistoryQuery) (*SelfConsumptionHistory, error) {
+	history := &SelfConsumptionHistory{}
+	if err := s.history(ctx, HistoryKindSelfConsumption, q, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Fetches a history of the given kind and decodes its response into out
+func (s *EnergySite) history(ctx context.Context, kind HistoryKind, q HistoryQuery, out interface{}) error {
+	if !q.StartDate.IsZero() && (q.EndDate.IsZero() || q.StartDate.After(q.EndDate)) {
+		return errors.New("history start date needs a later end date")
+	}
+	var path string
+	switch {
+	case !q.EndDate.IsZero():
+		var err error
+		if path, err = s.calendarHistoryPath(kind, q); err != nil {
+			return err
+		}
+	case q.TimeZone != nil:
+		return errors.New("history time zone needs an end date")
+	case q.Period == "":
+		path = s.historyPath(kind, HistoryPeriodDay)
+	default:
+		path = s.historyPath(kind, q.Period)
+	}
+	resp := &struct {
+		Response interface{} `json:"response"`
+	}{Response: out}
+	return s.c.getJSON(ctx, path, resp)
+}
+
+func (s *EnergySite) calendarHistoryPath(kind HistoryKind, q HistoryQuery) (string, error) {
+	loc := q.TimeZone
+	if loc == nil {
+		var err error
+		if loc, err = s.Location(); err != nil {
+			return "", fmt.Errorf("history needs a time zone: %w", err)
+		}
+	}
+	if loc.String() == "Local" {
+		return "", errors.New("history needs a named time zone instead of time.Local")
+	}
+	v := url.Values{}
+	v.Set("kind", string(kind))
+	if q.Period != "" {
+		v.Set("period", string(q.Period))
+	}
+	if !q.StartDate.IsZero() {
+		v.Set("start_date", q.StartDate.In(loc).Format(time.RFC3339))
+	}
+	v.Set("end_date", q.EndDate.In(loc).Format(time.RFC3339))
+	v.Set("time_zone", loc.String())
+	return s.basePath() + "/calendar_history?" + v.Encode(), nil
+}
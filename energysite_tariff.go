@@ -0,0 +1,470 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Tariff is the utility rate plan of a site. The year is split into seasons,
+// and the week of every season into time-of-use periods such as ON_PEAK and
+// OFF_PEAK. A tariff without seasons has a flat rate, stored as the rate of
+// the period "ALL" of the season "ALL".
+type Tariff struct {
+	Version  int    `json:"version"`
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Utility  string `json:"utility"`
+	Currency string `json:"currency"`
+	// DailyCharges are fixed charges per day.
+	DailyCharges []TariffCharge `json:"daily_charges,omitempty"`
+	// DemandCharges are charged per kW of the peak demand in each period.
+	DemandCharges SeasonRates `json:"demand_charges,omitempty"`
+	// EnergyCharges are the buy rates per kWh in each period.
+	EnergyCharges SeasonRates             `json:"energy_charges"`
+	Seasons       map[string]TariffSeason `json:"seasons"`
+	// SellTariff has the rates paid for energy exported to the grid. It uses
+	// the seasons of the tariff when it has none of its own.
+	SellTariff *SellTariff `json:"sell_tariff,omitempty"`
+}
+
+// SellTariff is the rate plan for energy exported to the grid.
+type SellTariff struct {
+	Name          string                  `json:"name,omitempty"`
+	Utility       string                  `json:"utility,omitempty"`
+	DailyCharges  []TariffCharge          `json:"daily_charges,omitempty"`
+	DemandCharges SeasonRates             `json:"demand_charges,omitempty"`
+	EnergyCharges SeasonRates             `json:"energy_charges"`
+	Seasons       map[string]TariffSeason `json:"seasons,omitempty"`
+}
+
+// TariffCharge is a fixed charge of a tariff.
+type TariffCharge struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// PeriodRates maps period names to rates.
+type PeriodRates map[string]float64
+
+// SeasonRates maps season names to the rates of their periods.
+type SeasonRates map[string]PeriodRates
+
+// TariffSeason is a range of days of the year, both ends included. A season
+// whose end comes before its start wraps around the new year. Tariffs may name
+// unused seasons with a zero TariffSeason, which is ignored.
+type TariffSeason struct {
+	FromMonth time.Month `json:"fromMonth"`
+	FromDay   int        `json:"fromDay"`
+	ToMonth   time.Month `json:"toMonth"`
+	ToDay     int        `json:"toDay"`
+	// TOUPeriods maps period names to the times of the week they apply.
+	TOUPeriods map[string][]TOUPeriod `json:"tou_periods"`
+}
+
+// TOUPeriod is a daily time range on the days from FromDayOfWeek to
+// ToDayOfWeek. A range whose end is not after its start runs past midnight,
+// so 00:00 to 00:00 is the whole day. Weeks start on Monday, as in the JSON
+// where Monday is 0 and Sunday is 6, so Saturday to Sunday is the weekend.
+type TOUPeriod struct {
+	FromDayOfWeek time.Weekday `json:"fromDayOfWeek"`
+	ToDayOfWeek   time.Weekday `json:"toDayOfWeek"`
+	FromHour      int          `json:"fromHour"`
+	FromMinute    int          `json:"fromMinute"`
+	ToHour        int          `json:"toHour"`
+	ToMinute      int          `json:"toMinute"`
+}
+
+// Validate checks that the seasons cover every day of the year exactly once,
+// that the periods of every season cover the week exactly once and that every
+// period has a buy and, with a sell tariff, a sell rate.
+func (t *Tariff) Validate() error {
+	buySeasons := usedSeasons(t.Seasons)
+	for _, name := range seasonNames(buySeasons) {
+		if err := buySeasons[name].validate(name); err != nil {
+			return err
+		}
+	}
+	if err := validateSeasonCoverage(buySeasons); err != nil {
+		return err
+	}
+	if err := validateRates("energy", t.EnergyCharges, buySeasons); err != nil {
+		return err
+	}
+	if t.SellTariff == nil {
+		return nil
+	}
+	seasons := usedSeasons(t.SellTariff.Seasons)
+	if len(seasons) == 0 {
+		seasons = buySeasons
+	} else {
+		for _, name := range seasonNames(seasons) {
+			if err := seasons[name].validate(name); err != nil {
+				return fmt.Errorf("sell tariff: %w", err)
+			}
+		}
+		if err := validateSeasonCoverage(seasons); err != nil {
+			return fmt.Errorf("sell tariff: %w", err)
+		}
+	}
+	return validateRates("sell", t.SellTariff.EnergyCharges, seasons)
+}
+
+// TimeOfUseSettingsRequest is the body of the time_of_use_settings command.
+type TimeOfUseSettingsRequest struct {
+	TOUSettings TOUSettings `json:"tou_settings"`
+}
+
+// TOUSettings holds the tariff of a TimeOfUseSettingsRequest. The tariff is
+// sent as tariff_content_v2, which nests the rates of every season under
+// "rates" and the times of every period under "periods".
+type TOUSettings struct {
+	TariffContent *Tariff `json:"-"`
+}
+
+// MarshalJSON encodes the tariff as tariff_content_v2.
+func (s TOUSettings) MarshalJSON() ([]byte, error) {
+	var v2 *tariffV2
+	if t := s.TariffContent; t != nil {
+		v2 = &tariffV2{
+			Version:       t.Version,
+			Code:          t.Code,
+			Name:          t.Name,
+			Utility:       t.Utility,
+			Currency:      t.Currency,
+			DailyCharges:  t.DailyCharges,
+			DemandCharges: seasonRatesV2(t.DemandCharges),
+			EnergyCharges: seasonRatesV2(t.EnergyCharges),
+			Seasons:       seasonsV2(t.Seasons),
+		}
+		if st := t.SellTariff; st != nil {
+			v2.SellTariff = &sellTariffV2{
+				Name:          st.Name,
+				Utility:       st.Utility,
+				DailyCharges:  st.DailyCharges,
+				DemandCharges: seasonRatesV2(st.DemandCharges),
+				EnergyCharges: seasonRatesV2(st.EnergyCharges),
+				Seasons:       seasonsV2(st.Seasons),
+			}
+		}
+	}
+	return json.Marshal(struct {
+		TariffContentV2 *tariffV2 `json:"tariff_content_v2"`
+	}{v2})
+}
+
+type tariffV2 struct {
+	Version       int                      `json:"version"`
+	Code          string                   `json:"code"`
+	Name          string                   `json:"name"`
+	Utility       string                   `json:"utility"`
+	Currency      string                   `json:"currency"`
+	DailyCharges  []TariffCharge           `json:"daily_charges,omitempty"`
+	DemandCharges map[string]periodRatesV2 `json:"demand_charges,omitempty"`
+	EnergyCharges map[string]periodRatesV2 `json:"energy_charges"`
+	Seasons       map[string]seasonV2      `json:"seasons"`
+	SellTariff    *sellTariffV2            `json:"sell_tariff,omitempty"`
+}
+
+type sellTariffV2 struct {
+	Name          string                   `json:"name,omitempty"`
+	Utility       string                   `json:"utility,omitempty"`
+	DailyCharges  []TariffCharge           `json:"daily_charges,omitempty"`
+	DemandCharges map[string]periodRatesV2 `json:"demand_charges,omitempty"`
+	EnergyCharges map[string]periodRatesV2 `json:"energy_charges"`
+	Seasons       map[string]seasonV2      `json:"seasons,omitempty"`
+}
+
+type periodRatesV2 struct {
+	Rates PeriodRates `json:"rates"`
+}
+
+// Unused seasons are sent as {}.
+type seasonV2 struct {
+	FromMonth  time.Month           `json:"fromMonth,omitempty"`
+	FromDay    int                  `json:"fromDay,omitempty"`
+	ToMonth    time.Month           `json:"toMonth,omitempty"`
+	ToDay      int                  `json:"toDay,omitempty"`
+	TOUPeriods map[string]periodsV2 `json:"tou_periods,omitempty"`
+}
+
+type periodsV2 struct {
+	Periods []TOUPeriod `json:"periods"`
+}
+
+func seasonRatesV2(rates SeasonRates) map[string]periodRatesV2 {
+	if rates == nil {
+		return nil
+	}
+	v2 := make(map[string]periodRatesV2, len(rates))
+	for season, r := range rates {
+		v2[season] = periodRatesV2{Rates: r}
+	}
+	return v2
+}
+
+func seasonsV2(seasons map[string]TariffSeason) map[string]seasonV2 {
+	if seasons == nil {
+		return nil
+	}
+	v2 := make(map[string]seasonV2, len(seasons))
+	for name, s := range seasons {
+		sv2 := seasonV2{FromMonth: s.FromMonth, FromDay: s.FromDay, ToMonth: s.ToMonth, ToDay: s.ToDay}
+		if len(s.TOUPeriods) > 0 {
+			sv2.TOUPeriods = make(map[string]periodsV2, len(s.TOUPeriods))
+			for period, ps := range s.TOUPeriods {
+				sv2.TOUPeriods[period] = periodsV2{Periods: ps}
+			}
+		}
+		v2[name] = sv2
+	}
+	return v2
+}
+
+// tariffPeriod is the JSON form of a TOUPeriod.
+type tariffPeriod struct {
+	FromDayOfWeek int `json:"fromDayOfWeek"`
+	ToDayOfWeek   int `json:"toDayOfWeek"`
+	FromHour      int `json:"fromHour"`
+	FromMinute    int `json:"fromMinute"`
+	ToHour        int `json:"toHour"`
+	ToMinute      int `json:"toMinute"`
+}
+
+// MarshalJSON numbers the days of the week from Monday.
+func (p TOUPeriod) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tariffPeriod{
+		FromDayOfWeek: tariffDay(p.FromDayOfWeek),
+		ToDayOfWeek:   tariffDay(p.ToDayOfWeek),
+		FromHour:      p.FromHour,
+		FromMinute:    p.FromMinute,
+		ToHour:        p.ToHour,
+		ToMinute:      p.ToMinute,
+	})
+}
+
+// UnmarshalJSON numbers the days of the week from Monday.
+func (p *TOUPeriod) UnmarshalJSON(data []byte) error {
+	var tp tariffPeriod
+	if err := json.Unmarshal(data, &tp); err != nil {
+		return err
+	}
+	*p = TOUPeriod{
+		FromDayOfWeek: weekday(tp.FromDayOfWeek),
+		ToDayOfWeek:   weekday(tp.ToDayOfWeek),
+		FromHour:      tp.FromHour,
+		FromMinute:    tp.FromMinute,
+		ToHour:        tp.ToHour,
+		ToMinute:      tp.ToMinute,
+	}
+	return nil
+}
+
+// SetTimeOfUseSettings replaces the tariff the site optimizes for in
+// autonomous mode.
+func (s *EnergySite) SetTimeOfUseSettings(ctx context.Context, tariff Tariff) error {
+	if err := tariff.Validate(); err != nil {
+		return err
+	}
+	if err := s.siteCommand(ctx, "timeOfUseSettings", "time_of_use_settings", &TimeOfUseSettingsRequest{
+		TOUSettings: TOUSettings{TariffContent: &tariff},
+	}); err != nil {
+		return err
+	}
+	s.TariffContent = &tariff
+	return nil
+}
+
+// Days are counted in a leap year so that seasons may include February 29.
+const (
+	tariffYear    = 2024
+	minutesPerDay = 24 * 60
+)
+
+func (s TariffSeason) unused() bool {
+	return s.FromMonth == 0 && s.FromDay == 0 && s.ToMonth == 0 && s.ToDay == 0 && len(s.TOUPeriods) == 0
+}
+
+func (s TariffSeason) validate(name string) error {
+	if _, err := seasonDay(s.FromMonth, s.FromDay); err != nil {
+		return fmt.Errorf("season %q starts on an invalid date: %w", name, err)
+	}
+	if _, err := seasonDay(s.ToMonth, s.ToDay); err != nil {
+		return fmt.Errorf("season %q ends on an invalid date: %w", name, err)
+	}
+	if len(s.TOUPeriods) == 0 {
+		return fmt.Errorf("season %q has no time-of-use periods", name)
+	}
+
+	var week [7 * minutesPerDay]string
+	for _, period := range periodNames(s.TOUPeriods) {
+		for _, p := range s.TOUPeriods[period] {
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("period %q of season %q: %w", period, name, err)
+			}
+			for _, m := range p.minutes() {
+				if other := week[m]; other != "" {
+					return fmt.Errorf("periods %q and %q of season %q overlap at %s", other, period, name, weekMinute(m))
+				}
+				week[m] = period
+			}
+		}
+	}
+	for m, period := range week {
+		if period == "" {
+			return fmt.Errorf("season %q has no period at %s", name, weekMinute(m))
+		}
+	}
+	return nil
+}
+
+func (p TOUPeriod) validate() error {
+	switch {
+	case p.FromDayOfWeek < time.Sunday || p.FromDayOfWeek > time.Saturday,
+		p.ToDayOfWeek < time.Sunday || p.ToDayOfWeek > time.Saturday:
+		return errors.New("day of week is not a time.Weekday")
+	case tariffDay(p.FromDayOfWeek) > tariffDay(p.ToDayOfWeek):
+		return fmt.Errorf("%v comes after %v", p.FromDayOfWeek, p.ToDayOfWeek)
+	case p.FromHour < 0 || p.FromHour > 23 || p.ToHour < 0 || p.ToHour > 23:
+		return errors.New("hour is not between 0 and 23")
+	case p.FromMinute < 0 || p.FromMinute > 59 || p.ToMinute < 0 || p.ToMinute > 59:
+		return errors.New("minute is not between 0 and 59")
+	}
+	return nil
+}
+
+// Returns the minutes of the week the period covers, counted from Monday 00:00.
+func (p TOUPeriod) minutes() []int {
+	from := p.FromHour*60 + p.FromMinute
+	to := p.ToHour*60 + p.ToMinute
+	var ms []int
+	for d := tariffDay(p.FromDayOfWeek); d <= tariffDay(p.ToDayOfWeek); d++ {
+		start := d * minutesPerDay
+		if to > from {
+			for m := from; m < to; m++ {
+				ms = append(ms, start+m)
+			}
+			continue
+		}
+		for m := 0; m < to; m++ {
+			ms = append(ms, start+m)
+		}
+		for m := from; m < minutesPerDay; m++ {
+			ms = append(ms, start+m)
+		}
+	}
+	return ms
+}
+
+func validateSeasonCoverage(seasons map[string]TariffSeason) error {
+	if len(seasons) == 0 {
+		return nil
+	}
+	days := make([]string, time.Date(tariffYear, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay())
+	for _, name := range seasonNames(seasons) {
+		s := seasons[name]
+		from, _ := seasonDay(s.FromMonth, s.FromDay)
+		to, _ := seasonDay(s.ToMonth, s.ToDay)
+		for d := from; ; d = (d + 1) % len(days) {
+			if other := days[d]; other != "" {
+				return fmt.Errorf("seasons %q and %q overlap on %s", other, name, yearDay(d))
+			}
+			days[d] = name
+			if d == to {
+				break
+			}
+		}
+	}
+	leapDay, _ := seasonDay(time.February, 29)
+	for d, name := range days {
+		// seasons written for common years may skip February 29
+		if name == "" && d == leapDay && days[d-1] != "" && days[d+1] != "" {
+			continue
+		}
+		if name == "" {
+			return fmt.Errorf("no season covers %s", yearDay(d))
+		}
+	}
+	return nil
+}
+
+func validateRates(kind string, rates SeasonRates, seasons map[string]TariffSeason) error {
+	if len(seasons) == 0 {
+		if _, ok := rates["ALL"]["ALL"]; !ok {
+			return fmt.Errorf("flat tariff has no %s rate", kind)
+		}
+		return nil
+	}
+	for _, season := range seasonNames(seasons) {
+		for _, period := range periodNames(seasons[season].TOUPeriods) {
+			if _, ok := rates[season][period]; !ok {
+				return fmt.Errorf("period %q of season %q has no %s rate", period, season, kind)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the zero-based day of the year of month and day.
+func seasonDay(month time.Month, day int) (int, error) {
+	t := time.Date(tariffYear, month, day, 0, 0, 0, 0, time.UTC)
+	if t.Month() != month || t.Day() != day || t.Year() != tariffYear {
+		return 0, fmt.Errorf("%v %d does not exist", month, day)
+	}
+	return t.YearDay() - 1, nil
+}
+
+func yearDay(d int) string {
+	return time.Date(tariffYear, time.January, d+1, 0, 0, 0, 0, time.UTC).Format("January 2")
+}
+
+func weekMinute(m int) string {
+	return fmt.Sprintf("%v %s", weekday(m/minutesPerDay), TimeOfDay(time.Duration(m%minutesPerDay)*time.Minute))
+}
+
+// Returns the day of a week starting on Monday, 0 to 6.
+func tariffDay(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// Returns the weekday of a day counted from Monday, or -1 for days outside
+// the week.
+func weekday(d int) time.Weekday {
+	if d < 0 || d > 6 {
+		return -1
+	}
+	return time.Weekday((d + 1) % 7)
+}
+
+// Returns the seasons without the unused ones.
+func usedSeasons(seasons map[string]TariffSeason) map[string]TariffSeason {
+	used := make(map[string]TariffSeason, len(seasons))
+	for name, s := range seasons {
+		if !s.unused() {
+			used[name] = s
+		}
+	}
+	return used
+}
+
+// Return the names in order, so that validation errors are stable.
+func seasonNames(seasons map[string]TariffSeason) []string {
+	names := make([]string, 0, len(seasons))
+	for name := range seasons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func periodNames(periods map[string][]TOUPeriod) []string {
+	names := make([]string, 0, len(periods))
+	for name := range periods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
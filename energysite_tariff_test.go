@@ -0,0 +1,214 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testTariff has a summer season with peak hours on weekdays and a winter
+// season that wraps around the new year.
+func testTariff() Tariff {
+	allWeek := []TOUPeriod{{FromDayOfWeek: time.Monday, ToDayOfWeek: time.Sunday}}
+	return Tariff{
+		Version:  1,
+		Code:     "EV2-A",
+		Name:     "Residential - EV2-A",
+		Utility:  "Pacific Gas & Electric Company",
+		Currency: "USD",
+		EnergyCharges: SeasonRates{
+			"Summer": {"ON_PEAK": 0.55, "OFF_PEAK": 0.31},
+			"Winter": {"OFF_PEAK": 0.29},
+		},
+		Seasons: map[string]TariffSeason{
+			"Summer": {FromMonth: time.June, FromDay: 1, ToMonth: time.September, ToDay: 30, TOUPeriods: map[string][]TOUPeriod{
+				"ON_PEAK": {{FromDayOfWeek: time.Monday, ToDayOfWeek: time.Friday, FromHour: 16, ToHour: 21}},
+				"OFF_PEAK": {
+					{FromDayOfWeek: time.Monday, ToDayOfWeek: time.Friday, FromHour: 21, ToHour: 16},
+					{FromDayOfWeek: time.Saturday, ToDayOfWeek: time.Sunday},
+				},
+			}},
+			"Winter": {FromMonth: time.October, FromDay: 1, ToMonth: time.May, ToDay: 31, TOUPeriods: map[string][]TOUPeriod{
+				"OFF_PEAK": allWeek,
+			}},
+		},
+		SellTariff: &SellTariff{
+			EnergyCharges: SeasonRates{
+				"Summer": {"ON_PEAK": 0.12, "OFF_PEAK": 0.04},
+				"Winter": {"OFF_PEAK": 0.03},
+			},
+		},
+	}
+}
+
+func TestTariffSpec(t *testing.T) {
+	Convey("Should accept a tariff covering the year and the week", t, func() {
+		tariff := testTariff()
+		So(tariff.Validate(), ShouldBeNil)
+	})
+
+	Convey("Should accept a flat tariff", t, func() {
+		tariff := Tariff{EnergyCharges: SeasonRates{"ALL": {"ALL": 0.3}}}
+		So(tariff.Validate(), ShouldBeNil)
+		tariff.EnergyCharges = nil
+		So(tariff.Validate(), ShouldNotBeNil)
+	})
+
+	Convey("Should ignore unused seasons", t, func() {
+		tariff := testTariff()
+		tariff.Seasons["Spring"] = TariffSeason{}
+		So(tariff.Validate(), ShouldBeNil)
+
+		flat := Tariff{
+			EnergyCharges: SeasonRates{"ALL": {"ALL": 0.3}},
+			Seasons:       map[string]TariffSeason{"Summer": {}, "Winter": {}},
+		}
+		So(flat.Validate(), ShouldBeNil)
+	})
+
+	Convey("Should start the week on Monday", t, func() {
+		tariff := testTariff()
+		winter := tariff.Seasons["Winter"].TOUPeriods["OFF_PEAK"]
+		winter[0].FromDayOfWeek, winter[0].ToDayOfWeek = time.Sunday, time.Saturday
+		So(tariff.Validate().Error(), ShouldEqual, `period "OFF_PEAK" of season "Winter": Sunday comes after Saturday`)
+
+		var p TOUPeriod
+		So(json.Unmarshal([]byte(`{"fromDayOfWeek":5,"toDayOfWeek":6,"fromHour":9}`), &p), ShouldBeNil)
+		So(p, ShouldResemble, TOUPeriod{FromDayOfWeek: time.Saturday, ToDayOfWeek: time.Sunday, FromHour: 9})
+		So(json.Unmarshal([]byte(`{"toDayOfWeek":7}`), &p), ShouldBeNil)
+		So(p.validate(), ShouldNotBeNil)
+	})
+
+	Convey("Should reject gaps between seasons", t, func() {
+		tariff := testTariff()
+		winter := tariff.Seasons["Winter"]
+		winter.ToMonth, winter.ToDay = time.May, 30
+		tariff.Seasons["Winter"] = winter
+		So(tariff.Validate().Error(), ShouldEqual, "no season covers May 31")
+	})
+
+	Convey("Should accept seasons that skip February 29", t, func() {
+		tariff := testTariff()
+		winter, summer := tariff.Seasons["Winter"], tariff.Seasons["Summer"]
+		winter.ToMonth, winter.ToDay = time.February, 28
+		summer.FromMonth = time.March
+		tariff.Seasons["Winter"], tariff.Seasons["Summer"] = winter, summer
+		So(tariff.Validate(), ShouldBeNil)
+
+		winter.ToDay = 27
+		tariff.Seasons["Winter"] = winter
+		So(tariff.Validate().Error(), ShouldEqual, "no season covers February 28")
+	})
+
+	Convey("Should reject overlapping seasons", t, func() {
+		tariff := testTariff()
+		winter := tariff.Seasons["Winter"]
+		winter.FromMonth = time.September
+		tariff.Seasons["Winter"] = winter
+		So(tariff.Validate().Error(), ShouldEqual, `seasons "Summer" and "Winter" overlap on September 1`)
+	})
+
+	Convey("Should reject invalid dates", t, func() {
+		tariff := testTariff()
+		summer := tariff.Seasons["Summer"]
+		summer.ToDay = 31
+		tariff.Seasons["Summer"] = summer
+		So(tariff.Validate(), ShouldNotBeNil)
+	})
+
+	Convey("Should reject gaps between periods", t, func() {
+		tariff := testTariff()
+		tariff.Seasons["Summer"].TOUPeriods["ON_PEAK"][0].FromHour = 17
+		So(tariff.Validate().Error(), ShouldEqual, `season "Summer" has no period at Monday 16:00`)
+	})
+
+	Convey("Should reject overlapping periods", t, func() {
+		tariff := testTariff()
+		tariff.Seasons["Summer"].TOUPeriods["ON_PEAK"][0].ToDayOfWeek = time.Saturday
+		So(tariff.Validate().Error(), ShouldEqual, `periods "OFF_PEAK" and "ON_PEAK" of season "Summer" overlap at Saturday 16:00`)
+	})
+
+	Convey("Should reject periods without rates", t, func() {
+		tariff := testTariff()
+		delete(tariff.SellTariff.EnergyCharges["Summer"], "ON_PEAK")
+		So(tariff.Validate().Error(), ShouldEqual, `period "ON_PEAK" of season "Summer" has no sell rate`)
+	})
+}
+
+func TestSetTimeOfUseSettingsSpec(t *testing.T) {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/energy_sites/12345/site_info", serveJSON(SiteInfoJSON))
+	mux.HandleFunc("/api/1/energy_sites/12345/time_of_use_settings", func(w http.ResponseWriter, req *http.Request) {
+		// decode the wire format independently of the Tariff types
+		var body struct {
+			TOUSettings struct {
+				TariffContent struct {
+					Code          string `json:"code"`
+					EnergyCharges map[string]struct {
+						Rates map[string]float64 `json:"rates"`
+					} `json:"energy_charges"`
+					Seasons map[string]struct {
+						FromMonth  int `json:"fromMonth"`
+						ToMonth    int `json:"toMonth"`
+						TOUPeriods map[string]struct {
+							Periods []map[string]int `json:"periods"`
+						} `json:"tou_periods"`
+					} `json:"seasons"`
+					SellTariff struct {
+						EnergyCharges map[string]struct {
+							Rates map[string]float64 `json:"rates"`
+						} `json:"energy_charges"`
+					} `json:"sell_tariff"`
+				} `json:"tariff_content_v2"`
+			} `json:"tou_settings"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			serveJSON(SiteCommandFailedJSON)(w, req)
+			return
+		}
+		tariff := body.TOUSettings.TariffContent
+		summer := tariff.Seasons["Summer"]
+		peak := summer.TOUPeriods["ON_PEAK"].Periods
+		if tariff.Code != "EV2-A" || summer.FromMonth != 6 || summer.ToMonth != 9 ||
+			len(peak) != 1 || peak[0]["fromHour"] != 16 || peak[0]["toHour"] != 21 ||
+			peak[0]["fromDayOfWeek"] != 0 || peak[0]["toDayOfWeek"] != 4 ||
+			tariff.EnergyCharges["Summer"].Rates["ON_PEAK"] != 0.55 ||
+			tariff.SellTariff.EnergyCharges["Winter"].Rates["OFF_PEAK"] != 0.03 {
+			serveJSON(SiteCommandFailedJSON)(w, req)
+			return
+		}
+		serveJSON(SiteCommandOKJSON)(w, req)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	site, err := NewTestClient(ts).EnergySite(12345)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	Convey("Should decode the tariff of the site info", t, func() {
+		So(site.TariffContent.Code, ShouldEqual, "EV2-A")
+		So(site.TariffContent.EnergyCharges["ALL"]["ALL"], ShouldEqual, 0)
+		So(site.TariffContent.Validate(), ShouldBeNil)
+	})
+
+	Convey("Should set the time of use settings", t, func() {
+		So(site.SetTimeOfUseSettings(ctx, testTariff()), ShouldBeNil)
+		So(site.TariffContent.Seasons, ShouldContainKey, "Summer")
+		So(site.TariffContent.SellTariff.EnergyCharges["Summer"]["ON_PEAK"], ShouldEqual, 0.12)
+	})
+
+	Convey("Should not send invalid tariffs", t, func() {
+		tariff := testTariff()
+		delete(tariff.Seasons, "Winter")
+		So(site.SetTimeOfUseSettings(ctx, tariff), ShouldNotBeNil)
+		So(site.TariffContent.Seasons, ShouldContainKey, "Winter")
+	})
+}
@@ -0,0 +1,240 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	SiteInfoJSON          = `{"response":{"id":"STE19700101-00001","site_name":"my site","backup_reserve_percent":20,"default_real_mode":"self_consumption","installation_date":"2022-01-01T00:00:00-08:00","user_settings":{"go_off_grid_test_banner_enabled":false,"storm_mode_enabled":true,"powerwall_onboarding_settings_set":true,"powerwall_tesla_electric_interested_in":false,"vpp_tour_enabled":true,"sync_grid_alert_enabled":true,"breaker_alert_enabled":false},"components":{"solar":true,"solar_type":"pv_panel","battery":true,"grid":true,"backup":true,"gateway":"teg","load_meter":true,"tou_capable":true,"storm_mode_capable":true,"off_grid_vehicle_charging_reserve_supported":true,"battery_type":"ac_powerwall","configurable":true,"grid_services_enabled":false,"gateways":[{"device_id":"gw-1","din":"1152100-14-J--TG123","serial_number":"TG123","part_number":"1152100-14-J","part_type":10,"part_name":"Tesla Backup Gateway 2","is_active":true,"site_id":"site-1","firmware_version":"24.4.0 0fe780c9","updated_datetime":"2024-05-14T00:00:00.000Z"}],"batteries":[{"device_id":"pw-1","din":"2012170-25-E--TG456","serial_number":"TG456","part_number":"2012170-25-E","part_type":2,"part_name":"Powerwall 2","nameplate_max_charge_power":5000,"nameplate_max_discharge_power":5000,"nameplate_energy":13500}],"wall_connectors":[{"device_id":"wc-1","din":"1529455-02-D--PGT789","part_type":11,"is_active":true}],"disallow_charge_from_grid_with_solar_installed":true,"customer_preferred_export_rule":"pv_only","net_meter_mode":"battery_ok","system_alerts_enabled":true},"version":"23.44.0 eb113390","battery_count":3,"nameplate_power":15000,"nameplate_energy":40500,"installation_time_zone":"America/Los_Angeles","off_grid_vehicle_charging_reserve_percent":65,"max_site_meter_power_ac":1000000000,"min_site_meter_power_ac":-1000000000,"geolocation":{"latitude":37.39,"longitude":-122.15,"source":"Site Address Preference"},"address":{"address_line1":"1 Main St","city":"Palo Alto","state":"CA","zip":"94301","country":"US"},"vpp_backup_reserve_percent":0,"utility":"Pacific Gas & Electric Company","tariff_id":"PGE-EV2-A","tariff_content":{"version":1,"code":"EV2-A","name":"Residential - EV2-A","utility":"Pacific Gas & Electric Company","currency":"USD","energy_charges":{"ALL":{"ALL":0}},"seasons":{}}}}`
+	SiteLiveStatusJSON    = `{"response":{"solar_power":3102,"energy_left":18020.89,"total_pack_energy":39343,"percentage_charged":45.8,"backup_capable":true,"battery_power":-3090,"load_power":2581,"grid_status":"Inactive","grid_services_active":false,"grid_power":-3028,"grid_services_power":0,"generator_power":0,"island_status":"off_grid_unintentional","storm_mode_active":true,"timestamp":"2024-01-01T12:00:00-08:00","wall_connectors":[{"din":"1529455-02-D--PGT789","wall_connector_state":2,"wall_connector_fault_state":2,"wall_connector_power":0}]}}`
+	SiteCommandOKJSON     = `{"response":{"code":201,"message":"Updated"}}`
+	SiteCommandFailedJSON = `{"response":{"code":400,"message":"Invalid request"}}`
+)
+
+// serveSiteCommand answers a site command whose body equals want.
+func serveSiteCommand(want string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != want {
+			serveJSON(SiteCommandFailedJSON)(w, req)
+			return
+		}
+		serveJSON(SiteCommandOKJSON)(w, req)
+	}
+}
+
+func newEnergySiteTestServer() *httptest.Server {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/energy_sites/12345/site_info", serveJSON(SiteInfoJSON))
+	mux.HandleFunc("/api/1/energy_sites/12345/live_status", serveJSON(SiteLiveStatusJSON))
+	mux.HandleFunc("/api/1/energy_sites/12345/backup", serveSiteCommand(`{"backup_reserve_percent":30}`))
+	mux.HandleFunc("/api/1/energy_sites/12345/operation", serveSiteCommand(`{"default_real_mode":"autonomous"}`))
+	mux.HandleFunc("/api/1/energy_sites/12345/storm_mode", serveSiteCommand(`{"enabled":true}`))
+	mux.HandleFunc("/api/1/energy_sites/12345/grid_import_export", serveSiteCommand(`{"disallow_charge_from_grid_with_solar_installed":false,"customer_preferred_export_rule":"battery_ok"}`))
+	mux.HandleFunc("/api/1/energy_sites/12345/off_grid_vehicle_charging_reserve", serveSiteCommand(`{"off_grid_vehicle_charging_reserve_percent":40}`))
+	return httptest.NewServer(mux)
+}
+
+func TestEnergySiteCommandsSpec(t *testing.T) {
+	ts := newEnergySiteTestServer()
+	defer ts.Close()
+
+	site, err := NewTestClient(ts).EnergySite(12345)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	Convey("Should decode the site info", t, func() {
+		So(site.ID, ShouldEqual, "STE19700101-00001")
+		So(site.InstallationDate.Year(), ShouldEqual, 2022)
+		So(site.BatteryCount, ShouldEqual, 3)
+		So(site.NameplatePower, ShouldEqual, 15000)
+		So(site.NameplateEnergy, ShouldEqual, 40500)
+		So(site.UserSettings.StormModeEnabled, ShouldBeTrue)
+		So(site.Components.StormModeCapable, ShouldBeTrue)
+		So(site.Components.CustomerPreferredExportRule, ShouldEqual, ExportPVOnly)
+		So(site.Components.Gateways, ShouldHaveLength, 1)
+		So(site.Components.Gateways[0].FirmwareVersion, ShouldEqual, "24.4.0 0fe780c9")
+		So(site.Components.Batteries[0].NameplateEnergy, ShouldEqual, 13500)
+		So(site.Components.WallConnectors[0].IsActive, ShouldBeTrue)
+		So(site.Address.City, ShouldEqual, "Palo Alto")
+		So(site.TariffID, ShouldEqual, "PGE-EV2-A")
+		So(site.TariffContent.Name, ShouldEqual, "Residential - EV2-A")
+
+		loc, err := site.Location()
+		So(err, ShouldBeNil)
+		So(loc.String(), ShouldEqual, "America/Los_Angeles")
+	})
+
+	Convey("Should fetch the live status", t, func() {
+		status, err := site.LiveStatus(ctx)
+		So(err, ShouldBeNil)
+		So(status.SolarPower, ShouldEqual, 3102)
+		So(status.BatteryPower, ShouldEqual, -3090)
+		So(status.GridPower, ShouldEqual, -3028)
+		So(status.LoadPower, ShouldEqual, 2581)
+		So(status.GridStatus, ShouldEqual, GridStatusInactive)
+		So(status.IslandStatus, ShouldEqual, IslandStatusOffGridUnintentional)
+		So(status.StormModeActive, ShouldBeTrue)
+		So(status.BackupCapable, ShouldBeTrue)
+		So(status.Timestamp.Hour(), ShouldEqual, 12)
+		So(status.WallConnectors, ShouldHaveLength, 1)
+	})
+
+	Convey("Should set the battery reserve", t, func() {
+		So(site.SetBatteryReserve(30), ShouldBeNil)
+		So(site.BackupReservePercent, ShouldEqual, 30)
+
+		err := site.SetBatteryReserve(31)
+		So(errors.Is(err, ErrCommandFailed), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "batteryReserve failed: Invalid request")
+		So(site.BackupReservePercent, ShouldEqual, 30)
+	})
+
+	Convey("Should set the operation mode", t, func() {
+		So(site.SetOperationMode(ctx, OperationModeAutonomous), ShouldBeNil)
+		So(site.DefaultRealMode, ShouldEqual, "autonomous")
+		So(site.SetOperationMode(ctx, "off_grid"), ShouldNotBeNil)
+
+		err := site.SetOperationMode(ctx, OperationModeBackup)
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.Endpoint, ShouldEqual, "/api/1/energy_sites/12345/operation")
+		So(site.DefaultRealMode, ShouldEqual, "autonomous")
+	})
+
+	Convey("Should set storm watch", t, func() {
+		So(site.SetStormWatch(ctx, true), ShouldBeNil)
+		So(site.UserSettings.StormModeEnabled, ShouldBeTrue)
+		So(site.SetStormWatch(ctx, false), ShouldNotBeNil)
+		So(site.UserSettings.StormModeEnabled, ShouldBeTrue)
+	})
+
+	Convey("Should set grid import and export", t, func() {
+		So(site.SetGridImportExport(ctx, true, ExportBatteryOK), ShouldBeNil)
+		So(site.Components.DisallowChargeFromGridWithSolar, ShouldBeFalse)
+		So(site.Components.CustomerPreferredExportRule, ShouldEqual, ExportBatteryOK)
+		So(site.SetGridImportExport(ctx, false, ExportBatteryOK), ShouldNotBeNil)
+		So(site.Components.DisallowChargeFromGridWithSolar, ShouldBeFalse)
+		So(site.SetGridImportExport(ctx, true, "everything"), ShouldNotBeNil)
+	})
+
+	Convey("Should set the off-grid vehicle charging reserve", t, func() {
+		So(site.SetOffGridVehicleChargingReserve(ctx, 40), ShouldBeNil)
+		So(site.OffGridVehicleChargingReservePercent, ShouldEqual, 40)
+		So(site.SetOffGridVehicleChargingReserve(ctx, 101), ShouldNotBeNil)
+	})
+}
+
+func TestEnergySiteHistorySpec(t *testing.T) {
+	var query url.Values
+	record := func(j string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			query = req.URL.Query()
+			serveJSON(j)(w, req)
+		}
+	}
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/energy_sites/12345/site_info", serveJSON(SiteInfoJSON))
+	mux.HandleFunc("/api/1/energy_sites/12345/history", func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("kind") {
+		case "power":
+			record(`{"response":{"serial_number":"TG123","installation_time_zone":"America/Los_Angeles","time_series":[{"timestamp":"2024-01-01T00:00:00-08:00","solar_power":0,"battery_power":1200,"grid_power":300.5,"grid_services_power":0,"generator_power":0},{"timestamp":"2024-01-01T00:05:00-08:00","solar_power":0,"battery_power":1100,"grid_power":310,"grid_services_power":0,"generator_power":0}]}}`)(w, req)
+		case "self_consumption":
+			record(`{"response":{"period":"day","timezone":"America/Los_Angeles","time_series":[{"timestamp":"2024-01-01T00:00:00-08:00","solar":42.5,"battery":37}]}}`)(w, req)
+		default:
+			http.Error(w, "unexpected kind", http.StatusBadRequest)
+		}
+	})
+	mux.HandleFunc("/api/1/energy_sites/12345/calendar_history", func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("kind") {
+		case "backup":
+			record(`{"response":{"events":[{"timestamp":"2023-12-24T18:30:00-08:00","duration":5400000}],"total_events":1}}`)(w, req)
+		case "energy":
+			record(`{"response":{"serial_number":"TG123","period":"month","time_series":[{"timestamp":"2023-12-01T00:00:00-08:00","solar_energy_exported":1234.5}]}}`)(w, req)
+		default:
+			http.Error(w, "unexpected kind", http.StatusBadRequest)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	site, err := NewTestClient(ts).EnergySite(12345)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	Convey("Should fetch the power history", t, func() {
+		history, err := site.PowerHistory(ctx, HistoryQuery{Period: HistoryPeriodDay})
+		So(err, ShouldBeNil)
+		So(query.Get("period"), ShouldEqual, "day")
+		So(history.TimeSeries, ShouldHaveLength, 2)
+		So(history.TimeSeries[0].BatteryPower, ShouldEqual, 1200)
+		So(history.TimeSeries[1].Timestamp.Sub(history.TimeSeries[0].Timestamp), ShouldEqual, 5*time.Minute)
+	})
+
+	Convey("Should default the period of the current history", t, func() {
+		_, err := site.PowerHistory(ctx, HistoryQuery{})
+		So(err, ShouldBeNil)
+		So(query.Get("period"), ShouldEqual, "day")
+
+		_, err = site.PowerHistory(ctx, HistoryQuery{Period: HistoryPeriodWeek, TimeZone: time.UTC})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should fetch the self-consumption history", t, func() {
+		history, err := site.SelfConsumptionHistory(ctx, HistoryQuery{Period: HistoryPeriodDay})
+		So(err, ShouldBeNil)
+		So(history.TimeSeries[0].Solar, ShouldEqual, 42.5)
+		So(history.TimeSeries[0].Battery, ShouldEqual, 37)
+	})
+
+	Convey("Should fetch backup events for a date range in the site's time zone", t, func() {
+		end := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+		history, err := site.BackupHistory(ctx, HistoryQuery{Period: HistoryPeriodYear, EndDate: end})
+		So(err, ShouldBeNil)
+		So(query.Get("end_date"), ShouldEqual, "2024-01-01T00:00:00-08:00")
+		So(query.Get("time_zone"), ShouldEqual, "America/Los_Angeles")
+		So(history.TotalEvents, ShouldEqual, 1)
+		So(history.Events[0].Duration, ShouldEqual, 90*time.Minute)
+	})
+
+	Convey("Should fetch the energy history of a custom range", t, func() {
+		start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)
+		history, err := site.EnergyHistory(ctx, HistoryQuery{Period: HistoryPeriodMonth, StartDate: start, EndDate: end, TimeZone: time.UTC})
+		So(err, ShouldBeNil)
+		So(query.Get("start_date"), ShouldEqual, "2023-12-01T00:00:00Z")
+		So(query.Get("end_date"), ShouldEqual, "2023-12-31T23:59:59Z")
+		So(query.Get("time_zone"), ShouldEqual, "UTC")
+		So(history.TimeSeries[0].SolarEnergyExported, ShouldEqual, 1234.5)
+
+		_, err = site.EnergyHistory(ctx, HistoryQuery{StartDate: end, EndDate: start})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should require a named time zone for date ranges", t, func() {
+		end := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+		_, err := site.BackupHistory(ctx, HistoryQuery{EndDate: end, TimeZone: time.Local})
+		So(err, ShouldNotBeNil)
+
+		noZone := *site
+		noZone.InstallationTimeZone = ""
+		_, err = noZone.BackupHistory(ctx, HistoryQuery{EndDate: end})
+		So(err, ShouldNotBeNil)
+		_, err = noZone.BackupHistory(ctx, HistoryQuery{EndDate: end, TimeZone: time.UTC})
+		So(err, ShouldBeNil)
+		So(query.Get("time_zone"), ShouldEqual, "UTC")
+	})
+}
@@ -0,0 +1,126 @@
+package tesla
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrVehicleUnavailable is matched by errors returned while the vehicle is
+	// asleep or offline (HTTP 408).
+	ErrVehicleUnavailable = errors.New("vehicle unavailable")
+	// ErrUnauthorized is matched by errors returned when the OAuth token is
+	// missing, expired or revoked (HTTP 401).
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is matched by errors returned when the API is throttling
+	// requests (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+	// ErrCommandFailed is matched by errors returned when the API accepted a
+	// command but the vehicle or energy site rejected it.
+	ErrCommandFailed = errors.New("command failed")
+)
+
+// APIError is the error returned when the Tesla API responds with a non-200
+// status or rejects a command. Use errors.Is with the Err* sentinels to branch
+// on the kind of failure, or errors.As to inspect the details.
+type APIError struct {
+	StatusCode       int
+	Status           string
+	Endpoint         string
+	ErrorCode        string
+	ErrorDescription string
+	Reason           string
+	Body             []byte
+}
+
+// Error returns the reason for rejected commands, or the HTTP status followed
+// by Tesla's error details otherwise.
+func (e *APIError) Error() string {
+	if e.StatusCode == http.StatusOK && e.Reason != "" {
+		return e.Reason
+	}
+	var parts []string
+	for _, p := range []string{e.Status, e.ErrorCode, e.ErrorDescription, e.Reason} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+// Is reports whether the error matches one of the package sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrVehicleUnavailable:
+		return e.StatusCode == http.StatusRequestTimeout
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrCommandFailed:
+		return e.StatusCode == http.StatusOK && e.Reason != ""
+	}
+	return false
+}
+
+// apiErrorBody is the error payload returned by the Tesla API.
+type apiErrorBody struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Reason           string `json:"reason"`
+	Response         struct {
+		Reason string `json:"reason"`
+	} `json:"response"`
+}
+
+// Builds an APIError from a non-200 HTTP response and its body.
+func newAPIError(req *http.Request, res *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Endpoint:   req.URL.Path,
+		Body:       body,
+	}
+	var b apiErrorBody
+	if json.Unmarshal(body, &b) == nil {
+		e.ErrorCode = b.Error
+		e.ErrorDescription = b.ErrorDescription
+		e.Reason = b.Reason
+		if e.Reason == "" {
+			e.Reason = b.Response.Reason
+		}
+	}
+	return e
+}
+
+// Checks the body of a command response for a rejection reason.
+func commandError(endpoint string, body []byte) error {
+	if len(body) == 0 {
+		return nil
+	}
+	response := &CommandResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return err
+	}
+	if !response.Response.Result && response.Response.Reason != "" {
+		return &APIError{
+			StatusCode: http.StatusOK,
+			Endpoint:   endpointPath(endpoint),
+			Reason:     response.Response.Reason,
+			Body:       body,
+		}
+	}
+	return nil
+}
+
+// Returns the path component of an API URL, leaving out any query parameters.
+func endpointPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
@@ -0,0 +1,88 @@
+package tesla
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	VehicleUnavailableJSON = `{"response":null,"error":"vehicle unavailable: {:error=>\"vehicle unavailable:\"}","error_description":""}`
+	InvalidTokenJSON       = `{"error":"invalid bearer token","error_description":"token expired"}`
+	VehicleAsleepJSON      = `{"response":null,"reason":"vehicle is asleep"}`
+)
+
+func serveStatus(code int, j string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(j))
+	}
+}
+
+func TestAPIErrorSpec(t *testing.T) {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", serveStatus(http.StatusRequestTimeout, VehicleUnavailableJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/mobile_enabled", serveStatus(http.StatusRequestTimeout, VehicleAsleepJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/command/honk_horn", serveStatus(http.StatusUnauthorized, InvalidTokenJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/command/flash_lights", serveStatus(http.StatusTooManyRequests, ""))
+	mux.HandleFunc("/api/1/vehicles/1234/command/charge_start", serveJSON(ChargedJSON))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+
+	Convey("Should report an asleep vehicle as unavailable", t, func() {
+		_, err := vehicle.Data()
+		So(errors.Is(err, ErrVehicleUnavailable), ShouldBeTrue)
+		So(errors.Is(err, ErrUnauthorized), ShouldBeFalse)
+
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.StatusCode, ShouldEqual, http.StatusRequestTimeout)
+		So(apiErr.Endpoint, ShouldEqual, "/api/1/vehicles/1234/vehicle_data")
+		So(apiErr.ErrorCode, ShouldStartWith, "vehicle unavailable")
+		So(string(apiErr.Body), ShouldEqual, VehicleUnavailableJSON)
+	})
+
+	Convey("Should not report an unavailable vehicle with a reason as failed", t, func() {
+		_, err := vehicle.MobileEnabled()
+		So(errors.Is(err, ErrVehicleUnavailable), ShouldBeTrue)
+		So(errors.Is(err, ErrCommandFailed), ShouldBeFalse)
+
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.Reason, ShouldEqual, "vehicle is asleep")
+	})
+
+	Convey("Should report an expired token as unauthorized", t, func() {
+		err := vehicle.HonkHorn()
+		So(errors.Is(err, ErrUnauthorized), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "401 Unauthorized: invalid bearer token: token expired")
+	})
+
+	Convey("Should report throttling as rate limited", t, func() {
+		err := vehicle.FlashLights()
+		So(errors.Is(err, ErrRateLimited), ShouldBeTrue)
+	})
+
+	Convey("Should report a rejected command as failed", t, func() {
+		err := vehicle.StartCharging()
+		So(errors.Is(err, ErrCommandFailed), ShouldBeTrue)
+		So(errors.Is(err, ErrVehicleUnavailable), ShouldBeFalse)
+		So(err.Error(), ShouldEqual, "complete")
+
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.Reason, ShouldEqual, "complete")
+		So(apiErr.Endpoint, ShouldEqual, "/api/1/vehicles/1234/command/charge_start")
+	})
+}
@@ -44,12 +44,12 @@ func main() {
 	fmt.Println(vehicle.LockDoors())
 	fmt.Println(vehicle.SetTemperature(72.0, 72.0))
 	fmt.Println(vehicle.Start(os.Getenv("TESLA_PASSWORD")))
-	fmt.Println(vehicle.OpenTrunk("rear"))
-	fmt.Println(vehicle.OpenTrunk("front"))
-	fmt.Println(vehicle.MovePanoRoof("vent", 0))
-	fmt.Println(vehicle.MovePanoRoof("open", 0))
-	fmt.Println(vehicle.MovePanoRoof("move", 50))
-	fmt.Println(vehicle.MovePanoRoof("close", 0))
+	fmt.Println(vehicle.OpenTrunk(tesla.TrunkRear))
+	fmt.Println(vehicle.OpenTrunk(tesla.TrunkFront))
+	fmt.Println(vehicle.MovePanoRoof(tesla.RoofVent, 0))
+	fmt.Println(vehicle.MovePanoRoof(tesla.RoofOpen, 0))
+	fmt.Println(vehicle.MovePanoRoof(tesla.RoofMove, 50))
+	fmt.Println(vehicle.MovePanoRoof(tesla.RoofClose, 0))
 	fmt.Println(vehicle.TriggerHomelink())
 
 	// // Take care with these, as the car will move
@@ -0,0 +1,209 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Region is a Fleet API region. Accounts and vehicles belong to exactly one
+// region and must be accessed through its base URL.
+type Region string
+
+const (
+	RegionNA Region = "na"
+	RegionEU Region = "eu"
+	RegionCN Region = "cn"
+)
+
+var fleetAPIHosts = map[Region]string{
+	RegionNA: "https://fleet-api.prd.na.vn.cloud.tesla.com",
+	RegionEU: "https://fleet-api.prd.eu.vn.cloud.tesla.com",
+	RegionCN: "https://fleet-api.prd.cn.vn.cloud.tesla.cn",
+}
+
+var fleetAuthEndpoints = map[Region]oauth2.Endpoint{
+	RegionNA: {
+		AuthURL:   "https://auth.tesla.com/oauth2/v3/authorize",
+		TokenURL:  "https://fleet-auth.prd.vn.cloud.tesla.com/oauth2/v3/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	},
+	RegionEU: {
+		AuthURL:   "https://auth.tesla.com/oauth2/v3/authorize",
+		TokenURL:  "https://fleet-auth.prd.vn.cloud.tesla.com/oauth2/v3/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	},
+	RegionCN: {
+		AuthURL:   "https://auth.tesla.cn/oauth2/v3/authorize",
+		TokenURL:  "https://auth.tesla.cn/oauth2/v3/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	},
+}
+
+// Scope is an OAuth scope granted to a Fleet API application.
+type Scope string
+
+const (
+	ScopeOpenID              Scope = "openid"
+	ScopeOfflineAccess       Scope = "offline_access"
+	ScopeUserData            Scope = "user_data"
+	ScopeVehicleDeviceData   Scope = "vehicle_device_data"
+	ScopeVehicleCmds         Scope = "vehicle_cmds"
+	ScopeVehicleChargingCmds Scope = "vehicle_charging_cmds"
+	ScopeEnergyDeviceData    Scope = "energy_device_data"
+	ScopeEnergyCmds          Scope = "energy_cmds"
+)
+
+// FleetAPIURL returns the Fleet API base URL for standard API calls in the region.
+func FleetAPIURL(r Region) (string, error) {
+	host, ok := fleetAPIHosts[r]
+	if !ok {
+		return "", errors.New("unknown Fleet API region " + string(r))
+	}
+	return host + "/api/1", nil
+}
+
+// FleetApp is a third-party application registered with Tesla for the Fleet API.
+type FleetApp struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []Scope
+	// Region selects the authentication servers and the audience of partner
+	// tokens. Defaults to RegionNA.
+	Region Region
+	// Endpoint overrides the authentication servers of the region.
+	Endpoint oauth2.Endpoint
+}
+
+func (a FleetApp) region() Region {
+	if a.Region == "" {
+		return RegionNA
+	}
+	return a.Region
+}
+
+func (a FleetApp) endpoint() oauth2.Endpoint {
+	if a.Endpoint.TokenURL != "" {
+		return a.Endpoint
+	}
+	return fleetAuthEndpoints[a.region()]
+}
+
+func (a FleetApp) scopes() []string {
+	scopes := make([]string, len(a.Scopes))
+	for i, s := range a.Scopes {
+		scopes[i] = string(s)
+	}
+	return scopes
+}
+
+// OAuth2Config returns the configuration for authorizing users of the application.
+func (a FleetApp) OAuth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     a.ClientID,
+		ClientSecret: a.ClientSecret,
+		RedirectURL:  a.RedirectURL,
+		Endpoint:     a.endpoint(),
+		Scopes:       a.scopes(),
+	}
+}
+
+// PartnerToken requests a partner authentication token for the application
+// using the client credentials grant. Partner tokens are needed for partner
+// endpoints such as registering the application in a region.
+func (a FleetApp) PartnerToken(ctx context.Context) (*oauth2.Token, error) {
+	audience, ok := fleetAPIHosts[a.region()]
+	if !ok {
+		return nil, errors.New("unknown Fleet API region " + string(a.region()))
+	}
+	cc := &clientcredentials.Config{
+		ClientID:       a.ClientID,
+		ClientSecret:   a.ClientSecret,
+		TokenURL:       a.endpoint().TokenURL,
+		Scopes:         a.scopes(),
+		EndpointParams: url.Values{"audience": {audience}},
+		AuthStyle:      oauth2.AuthStyleInParams,
+	}
+	return cc.Token(ctx)
+}
+
+// WithFleetAPI makes the client use the Fleet API of the given region instead
+// of the owner API.
+func WithFleetAPI(r Region) ClientOption {
+	return func(c *Client) error {
+		u, err := FleetAPIURL(r)
+		if err != nil {
+			return err
+		}
+		c.baseURL = u
+		return nil
+	}
+}
+
+// WithFleetApp authenticates the client as the given third-party application,
+// which is used when refreshing the token.
+func WithFleetApp(app FleetApp) ClientOption {
+	return func(c *Client) error {
+		if app.ClientID == "" {
+			return errors.New("a Fleet API application needs a client ID")
+		}
+		c.oc = app.OAuth2Config()
+		return nil
+	}
+}
+
+// WithRegionDiscovery looks up the region of the account when the client is
+// created and uses the matching Fleet API base URL. It needs no WithFleetAPI.
+func WithRegionDiscovery() ClientOption {
+	return func(c *Client) error {
+		c.discoverRegion = true
+		return nil
+	}
+}
+
+// UserRegion is the Fleet API region of the authenticated account.
+type UserRegion struct {
+	Region          Region `json:"region"`
+	FleetAPIBaseURL string `json:"fleet_api_base_url"`
+}
+
+// UserRegionResponse contains the account region from the Tesla API.
+type UserRegionResponse struct {
+	Response *UserRegion `json:"response"`
+}
+
+// UserRegion fetches the region of the authenticated account. The owner API
+// has no region endpoint, so clients using it ask the North American Fleet API,
+// which answers for accounts of every region.
+func (c *Client) UserRegion(ctx context.Context) (*UserRegion, error) {
+	base := c.baseURL
+	if base == ownerAPIURL {
+		base, _ = FleetAPIURL(RegionNA)
+	}
+	resp := &UserRegionResponse{}
+	if err := c.getJSON(ctx, base+"/users/region", resp); err != nil {
+		return nil, err
+	}
+	if resp.Response == nil {
+		return nil, errors.New("region response is empty")
+	}
+	return resp.Response, nil
+}
+
+// Switches the client to the Fleet API base URL of the account's region.
+func (c *Client) useDiscoveredRegion(ctx context.Context) error {
+	region, err := c.UserRegion(ctx)
+	if err != nil {
+		return err
+	}
+	if region.FleetAPIBaseURL == "" {
+		return errors.New("region response has no Fleet API base URL")
+	}
+	c.baseURL = strings.TrimSuffix(region.FleetAPIBaseURL, "/") + "/api/1"
+	return nil
+}
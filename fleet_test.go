@@ -0,0 +1,134 @@
+package tesla
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+)
+
+func TestFleetSpec(t *testing.T) {
+	Convey("Should resolve regional base URLs", t, func() {
+		u, err := FleetAPIURL(RegionEU)
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "https://fleet-api.prd.eu.vn.cloud.tesla.com/api/1")
+
+		_, err = FleetAPIURL(Region("mars"))
+		So(err, ShouldNotBeNil)
+
+		c := &Client{}
+		So(WithFleetAPI(RegionCN)(c), ShouldBeNil)
+		So(c.baseURL, ShouldEqual, "https://fleet-api.prd.cn.vn.cloud.tesla.cn/api/1")
+	})
+
+	Convey("Should build the OAuth configuration of an application", t, func() {
+		app := FleetApp{
+			ClientID:    "app",
+			RedirectURL: "https://example.com/callback",
+			Scopes:      []Scope{ScopeOpenID, ScopeOfflineAccess, ScopeVehicleDeviceData},
+			Region:      RegionCN,
+		}
+		c := &Client{}
+		So(WithFleetApp(app)(c), ShouldBeNil)
+		So(c.oc.ClientID, ShouldEqual, "app")
+		So(c.oc.Endpoint.TokenURL, ShouldEqual, "https://auth.tesla.cn/oauth2/v3/token")
+		So(c.oc.Scopes, ShouldResemble, []string{"openid", "offline_access", "vehicle_device_data"})
+
+		So(WithFleetApp(FleetApp{})(c), ShouldNotBeNil)
+	})
+
+	Convey("Should request partner tokens with client credentials", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if err := req.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for k, want := range map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "app",
+				"client_secret": "secret",
+				"audience":      "https://fleet-api.prd.eu.vn.cloud.tesla.com",
+				"scope":         "openid vehicle_cmds energy_cmds",
+			} {
+				if got := req.Form.Get(k); got != want {
+					http.Error(w, fmt.Sprintf("%s: got %q want %q", k, got, want), http.StatusBadRequest)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"partner","token_type":"Bearer","expires_in":28800}`))
+		}))
+		defer ts.Close()
+
+		app := FleetApp{
+			ClientID:     "app",
+			ClientSecret: "secret",
+			Scopes:       []Scope{ScopeOpenID, ScopeVehicleCmds, ScopeEnergyCmds},
+			Region:       RegionEU,
+			Endpoint:     oauth2.Endpoint{TokenURL: ts.URL + "/oauth2/v3/token"},
+		}
+		tok, err := app.PartnerToken(context.Background())
+		So(err, ShouldBeNil)
+		So(tok.AccessToken, ShouldEqual, "partner")
+	})
+
+	Convey("Should switch to the discovered region", t, func() {
+		regional := httptest.NewServer(testMux)
+		defer regional.Close()
+
+		mux := new(http.ServeMux)
+		mux.HandleFunc("/api/1/users/region", serveJSON(`{"response":{"region":"eu","fleet_api_base_url":"`+regional.URL+`"}}`))
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		client, err := NewClient(context.Background(),
+			WithToken(&oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}),
+			WithBaseURL(ts.URL+"/api/1"),
+			WithRegionDiscovery(),
+		)
+		So(err, ShouldBeNil)
+		So(client.baseURL, ShouldEqual, regional.URL+"/api/1")
+
+		vehicles, err := client.Vehicles()
+		So(err, ShouldBeNil)
+		So(vehicles[0].DisplayName, ShouldEqual, "Macak")
+	})
+	Convey("Should discover the region without a Fleet API base URL", t, func() {
+		regional := httptest.NewServer(testMux)
+		defer regional.Close()
+
+		var host string
+		mux := new(http.ServeMux)
+		mux.HandleFunc("/api/1/users/region", func(w http.ResponseWriter, req *http.Request) {
+			host = req.Host
+			serveJSON(`{"response":{"region":"na","fleet_api_base_url":"`+regional.URL+`"}}`)(w, req)
+		})
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+
+		// route every request to ts while keeping the requested host
+		hc := &http.Client{Transport: rewriteHost{ts.Listener.Addr().String()}}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, hc)
+		client, err := NewClient(ctx,
+			WithToken(&oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}),
+			WithRegionDiscovery(),
+		)
+		So(err, ShouldBeNil)
+		So(host, ShouldEqual, "fleet-api.prd.na.vn.cloud.tesla.com")
+		So(client.baseURL, ShouldEqual, regional.URL+"/api/1")
+	})
+}
+
+type rewriteHost struct{ addr string }
+
+func (r rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = r.addr
+	return http.DefaultTransport.RoundTrip(req)
+}
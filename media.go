@@ -0,0 +1,77 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxMediaVolume is the highest volume, used when the vehicle does not
+// report its own.
+const DefaultMaxMediaVolume = 11
+
+// MediaTogglePlayback toggles between play and pause.
+func (v *Vehicle) MediaTogglePlayback(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_toggle_playback")
+}
+
+// MediaNextTrack skips to the next track.
+func (v *Vehicle) MediaNextTrack(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_next_track")
+}
+
+// MediaPrevTrack skips to the previous track.
+func (v *Vehicle) MediaPrevTrack(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_prev_track")
+}
+
+// MediaNextFavorite skips to the next favorite.
+func (v *Vehicle) MediaNextFavorite(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_next_fav")
+}
+
+// MediaPrevFavorite skips to the previous favorite.
+func (v *Vehicle) MediaPrevFavorite(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_prev_fav")
+}
+
+// MediaVolumeUp turns the volume up by one step.
+func (v *Vehicle) MediaVolumeUp(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_volume_up")
+}
+
+// MediaVolumeDown turns the volume down by one step.
+func (v *Vehicle) MediaVolumeDown(ctx context.Context) error {
+	return v.mediaCommand(ctx, "media_volume_down")
+}
+
+// AdjustVolumeRequest is the body of the adjust_volume command.
+type AdjustVolumeRequest struct {
+	Volume float64 `json:"volume"`
+}
+
+// AdjustVolume sets the volume, between 0 and the AudioVolumeMax reported by
+// the vehicle. The vehicle must have a user present.
+func (v *Vehicle) AdjustVolume(ctx context.Context, volume float64) error {
+	data, err := v.DataFor(ctx, DataEndpointVehicleState)
+	if err != nil {
+		return err
+	}
+	if data.Response.VehicleState == nil {
+		return errors.New("vehicle did not report its vehicle state")
+	}
+	max := data.Response.VehicleState.MediaInfo.AudioVolumeMax
+	if max == 0 {
+		max = DefaultMaxMediaVolume
+	}
+	if volume < 0 || volume > max {
+		return fmt.Errorf("volume %g is not between 0 and %g", volume, max)
+	}
+	return v.sendCommandRequest(ctx, "adjust_volume", &AdjustVolumeRequest{Volume: volume})
+}
+
+// Sends a media command without parameters
+func (v *Vehicle) mediaCommand(ctx context.Context, command string) error {
+	_, err := v.sendCommand(ctx, v.commandPath(command), nil)
+	return err
+}
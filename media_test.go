@@ -0,0 +1,39 @@
+package tesla
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMediaSpec(t *testing.T) {
+	ts := serveHTTP(t)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+	ctx := context.Background()
+
+	Convey("Should control the media player", t, func() {
+		So(vehicle.MediaTogglePlayback(ctx), ShouldBeNil)
+		So(vehicle.MediaNextTrack(ctx), ShouldBeNil)
+		So(vehicle.MediaPrevTrack(ctx), ShouldBeNil)
+		So(vehicle.MediaNextFavorite(ctx), ShouldBeNil)
+		So(vehicle.MediaPrevFavorite(ctx), ShouldBeNil)
+		So(vehicle.MediaVolumeUp(ctx), ShouldBeNil)
+		So(vehicle.MediaVolumeDown(ctx), ShouldBeNil)
+	})
+
+	Convey("Should adjust the volume", t, func() {
+		So(vehicle.AdjustVolume(ctx, 4.5), ShouldBeNil)
+		err := vehicle.AdjustVolume(ctx, 10.5)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "between 0 and 10.33")
+		So(vehicle.AdjustVolume(ctx, -1), ShouldNotBeNil)
+	})
+}
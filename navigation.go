@@ -0,0 +1,84 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShareRequest is the body of the share command.
+type ShareRequest struct {
+	Type        string            `json:"type"`
+	Value       map[string]string `json:"value"`
+	Locale      string            `json:"locale"`
+	TimestampMs string            `json:"timestamp_ms"`
+}
+
+// ShareAddress sends an address or other text to the vehicle's navigation, as
+// sharing it from the phone app does. locale is a language tag such as en-US.
+func (v *Vehicle) ShareAddress(ctx context.Context, text, locale string) error {
+	if text == "" {
+		return errors.New("nothing to share")
+	}
+	return v.sendCommandRequest(ctx, "share", &ShareRequest{
+		Type:        "share_ext_content_raw",
+		Value:       map[string]string{"android.intent.extra.TEXT": text},
+		Locale:      locale,
+		TimestampMs: strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
+	})
+}
+
+// NavigationGPSRequest is the body of the navigation_gps_request command.
+type NavigationGPSRequest struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Order int     `json:"order"`
+}
+
+// NavigateToGPS navigates to the coordinates. order is the position of the
+// destination in the trip, 0 replacing the current destination.
+func (v *Vehicle) NavigateToGPS(ctx context.Context, lat, lon float64, order int) error {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return errors.New("coordinates out of range")
+	}
+	return v.sendCommandRequest(ctx, "navigation_gps_request", &NavigationGPSRequest{Lat: lat, Lon: lon, Order: order})
+}
+
+// NavigationSuperchargerRequest is the body of the navigation_sc_request command.
+type NavigationSuperchargerRequest struct {
+	ID    int `json:"id"`
+	Order int `json:"order"`
+}
+
+// NavigateToSupercharger navigates to the Supercharger with the given ID.
+func (v *Vehicle) NavigateToSupercharger(ctx context.Context, id int) error {
+	return v.sendCommandRequest(ctx, "navigation_sc_request", &NavigationSuperchargerRequest{ID: id})
+}
+
+// Place is a stop of a multi-stop route.
+type Place struct {
+	// PlaceID is the Google Maps place ID of the stop.
+	PlaceID string
+}
+
+// NavigationWaypointsRequest is the body of the navigation_waypoints_request command.
+type NavigationWaypointsRequest struct {
+	Waypoints string `json:"waypoints"`
+}
+
+// NavigationWaypoints navigates along a route through the places in order.
+func (v *Vehicle) NavigationWaypoints(ctx context.Context, places []Place) error {
+	if len(places) == 0 {
+		return errors.New("a route needs at least one place")
+	}
+	refs := make([]string, len(places))
+	for i, p := range places {
+		if p.PlaceID == "" {
+			return errors.New("place " + strconv.Itoa(i) + " has no place ID")
+		}
+		refs[i] = "refId:" + p.PlaceID
+	}
+	return v.sendCommandRequest(ctx, "navigation_waypoints_request", &NavigationWaypointsRequest{Waypoints: strings.Join(refs, ",")})
+}
@@ -0,0 +1,41 @@
+package tesla
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNavigationSpec(t *testing.T) {
+	ts := serveHTTP(t)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+	ctx := context.Background()
+
+	Convey("Should share an address", t, func() {
+		So(vehicle.ShareAddress(ctx, "1 Infinite Loop, Cupertino", "en-US"), ShouldBeNil)
+		So(vehicle.ShareAddress(ctx, "", "en-US"), ShouldNotBeNil)
+	})
+
+	Convey("Should navigate to coordinates", t, func() {
+		So(vehicle.NavigateToGPS(ctx, 35.1, -20.2, 1), ShouldBeNil)
+		So(vehicle.NavigateToGPS(ctx, 91, 0, 0), ShouldNotBeNil)
+	})
+
+	Convey("Should navigate to a Supercharger", t, func() {
+		So(vehicle.NavigateToSupercharger(ctx, 42), ShouldBeNil)
+	})
+
+	Convey("Should navigate along waypoints", t, func() {
+		So(vehicle.NavigationWaypoints(ctx, []Place{{PlaceID: "ChIJa"}, {PlaceID: "ChIJb"}}), ShouldBeNil)
+		So(vehicle.NavigationWaypoints(ctx, nil), ShouldNotBeNil)
+		So(vehicle.NavigationWaypoints(ctx, []Place{{}}), ShouldNotBeNil)
+	})
+}
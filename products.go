@@ -1,6 +1,7 @@
 package tesla
 
 import (
+	"context"
 	"encoding/json"
 )
 
@@ -41,8 +42,13 @@ type ProductsResponse struct {
 
 // Products fetches the products associated to a Tesla account via the API.
 func (c *Client) Products() ([]*Product, error) {
+	return c.ProductsContext(context.Background())
+}
+
+// ProductsContext is like Products but uses ctx for the request.
+func (c *Client) ProductsContext(ctx context.Context) ([]*Product, error) {
 	productsResponse := &ProductsResponse{}
-	if err := c.getJSON(c.baseURL+"/products", productsResponse); err != nil {
+	if err := c.getJSON(ctx, c.baseURL+"/products", productsResponse); err != nil {
 		return nil, err
 	}
 	for _, v := range productsResponse.Response {
@@ -0,0 +1,178 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries requests that fail with a
+// transient error. Reads (GET) are idempotent and retried on any of the
+// RetryableStatusCodes; commands (POST) are only retried on the status codes
+// listed in RetryableCommandStatusCodes, which the API returns before acting
+// on the request.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, including delays requested
+	// through Retry-After.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction of its value.
+	Jitter float64
+	// RetryableStatusCodes are the HTTP status codes retried for
+	// RetryableMethods.
+	RetryableStatusCodes []int
+	// RetryableMethods are the idempotent HTTP methods that may be retried on
+	// RetryableStatusCodes and on network errors.
+	RetryableMethods []string
+	// RetryableCommandStatusCodes are the HTTP status codes retried for all
+	// other methods.
+	RetryableCommandStatusCodes []int
+	// RespectRetryAfter waits for the duration sent in a Retry-After header
+	// instead of the computed backoff.
+	RespectRetryAfter bool
+}
+
+// DefaultRetryPolicy returns a policy that makes up to 4 attempts with an
+// exponential backoff starting at 1s, retrying reads on 408, 429 and 5xx and
+// commands on 429 only.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableMethods:            []string{http.MethodGet},
+		RetryableCommandStatusCodes: []int{http.StatusTooManyRequests},
+		RespectRetryAfter:           true,
+	}
+}
+
+// WithRetryPolicy makes the client retry failed requests according to p. By
+// default requests are attempted once.
+func WithRetryPolicy(p *RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		if p != nil && p.MaxAttempts < 1 {
+			return errors.New("retry policy must allow at least one attempt")
+		}
+		c.retry = p
+		return nil
+	}
+}
+
+// Reports whether a request which failed with err on the given attempt should
+// be retried, and how long to wait before doing so.
+func (p *RetryPolicy) next(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts || req.Context().Err() != nil {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+
+	idempotent := containsString(p.RetryableMethods, req.Method)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		codes := p.RetryableCommandStatusCodes
+		if idempotent {
+			codes = p.RetryableStatusCodes
+		}
+		if !containsInt(codes, apiErr.StatusCode) {
+			return 0, false
+		}
+	case !idempotent:
+		return 0, false
+	}
+
+	if p.RespectRetryAfter && res != nil {
+		if d, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			return p.cap(d), true
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+// Computes the jittered exponential delay after the given attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return p.cap(time.Duration(d))
+}
+
+func (p *RetryPolicy) cap(d time.Duration) time.Duration {
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Parses a Retry-After header given either in seconds or as an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, secs >= 0
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// Waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(s []int, v int) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flaky fails the first n requests with the given status before serving j.
+func flaky(n int32, status int, header http.Header, j string) (http.HandlerFunc, *int32) {
+	var calls int32
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if atomic.AddInt32(&calls, 1) <= n {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		if req.Method == http.MethodPost && string(body) != `{"percent":50}` {
+			http.Error(w, "body not replayed", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(j))
+	}, &calls
+}
+
+func testRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = 10 * time.Millisecond
+	p.Jitter = 0
+	return p
+}
+
+func TestRetrySpec(t *testing.T) {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	data, dataCalls := flaky(2, http.StatusServiceUnavailable, nil, DataJSON)
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", data)
+	honk, honkCalls := flaky(1, http.StatusServiceUnavailable, nil, CommandResponseJSON)
+	mux.HandleFunc("/api/1/vehicles/1234/command/honk_horn", honk)
+	limit, limitCalls := flaky(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}, CommandResponseJSON)
+	mux.HandleFunc("/api/1/vehicles/1234/command/set_charge_limit", limit)
+	sites, sitesCalls := flaky(10, http.StatusBadGateway, nil, ProductsJSON)
+	mux.HandleFunc("/api/1/products", sites)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	client.retry = testRetryPolicy()
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+
+	Convey("Should retry reads until they succeed", t, func() {
+		_, err := vehicle.Data()
+		So(err, ShouldBeNil)
+		So(atomic.LoadInt32(dataCalls), ShouldEqual, 3)
+	})
+
+	Convey("Should not retry commands on server errors", t, func() {
+		err := vehicle.HonkHorn()
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(honkCalls), ShouldEqual, 1)
+	})
+
+	Convey("Should retry rate limited commands and replay the body", t, func() {
+		err := vehicle.SetChargeLimit(50)
+		So(err, ShouldBeNil)
+		So(atomic.LoadInt32(limitCalls), ShouldEqual, 2)
+	})
+
+	Convey("Should give up after the maximum number of attempts", t, func() {
+		_, err := client.Products()
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.StatusCode, ShouldEqual, http.StatusBadGateway)
+		So(atomic.LoadInt32(sitesCalls), ShouldEqual, 4)
+	})
+
+	Convey("Should stop retrying when the context is canceled", t, func() {
+		client := NewTestClient(ts)
+		client.retry = testRetryPolicy()
+		client.retry.InitialBackoff = time.Hour
+		client.retry.MaxBackoff = time.Hour
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.ProductsContext(ctx)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+	})
+}
+
+func TestRetryAfterSpec(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Convey("Should parse Retry-After seconds", t, func() {
+		d, ok := retryAfter("7", now)
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 7*time.Second)
+	})
+
+	Convey("Should parse Retry-After dates", t, func() {
+		d, ok := retryAfter(now.Add(time.Minute).Format(http.TimeFormat), now)
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, time.Minute)
+	})
+
+	Convey("Should ignore invalid Retry-After values", t, func() {
+		_, ok := retryAfter("soon", now)
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Should grow the backoff up to the maximum", t, func() {
+		p := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+		So(p.backoff(1), ShouldEqual, time.Second)
+		So(p.backoff(2), ShouldEqual, 2*time.Second)
+		So(p.backoff(3), ShouldEqual, 4*time.Second)
+		So(p.backoff(4), ShouldEqual, 5*time.Second)
+	})
+}
@@ -0,0 +1,186 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeOfDay is a time of day in the vehicle's time zone, measured from midnight.
+// It is sent to the vehicle as whole minutes.
+type TimeOfDay time.Duration
+
+// At returns the time of day at hour and minute.
+func At(hour, minute int) TimeOfDay {
+	return TimeOfDay(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+// Minutes returns the number of whole minutes since midnight.
+func (t TimeOfDay) Minutes() int {
+	return int(time.Duration(t) / time.Minute)
+}
+
+// String formats the time of day as HH:MM.
+func (t TimeOfDay) String() string {
+	m := t.Minutes()
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+// MarshalJSON encodes the time of day as minutes since midnight.
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Minutes())
+}
+
+// UnmarshalJSON decodes minutes since midnight.
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var m int
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*t = TimeOfDay(time.Duration(m) * time.Minute)
+	return nil
+}
+
+func (t TimeOfDay) validate() error {
+	if t < 0 || time.Duration(t) >= 24*time.Hour {
+		return fmt.Errorf("time of day %v is not between 00:00 and 23:59", time.Duration(t))
+	}
+	return nil
+}
+
+// DaysOfWeek selects the days a schedule applies to.
+type DaysOfWeek string
+
+const (
+	AllDays  DaysOfWeek = "All"
+	Weekdays DaysOfWeek = "Weekdays"
+)
+
+// Days returns the selection of the given days.
+func Days(days ...time.Weekday) DaysOfWeek {
+	names := make([]string, len(days))
+	for i, d := range days {
+		names[i] = d.String()
+	}
+	return DaysOfWeek(strings.Join(names, ","))
+}
+
+// ScheduledChargingRequest is the body of the set_scheduled_charging command.
+type ScheduledChargingRequest struct {
+	Enable bool      `json:"enable"`
+	Time   TimeOfDay `json:"time"`
+}
+
+// SetScheduledCharging enables or disables charging that starts at the given time of day.
+func (v *Vehicle) SetScheduledCharging(ctx context.Context, enable bool, start TimeOfDay) error {
+	if err := start.validate(); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, "set_scheduled_charging", &ScheduledChargingRequest{Enable: enable, Time: start})
+}
+
+// ScheduledDepartureRequest is the body of the set_scheduled_departure command.
+type ScheduledDepartureRequest struct {
+	Enable        bool      `json:"enable"`
+	DepartureTime TimeOfDay `json:"departure_time"`
+	// PreconditioningEnabled has the cabin at temperature by the departure time.
+	PreconditioningEnabled      bool `json:"preconditioning_enabled"`
+	PreconditioningWeekdaysOnly bool `json:"preconditioning_weekdays_only"`
+	// OffPeakChargingEnabled delays charging to off-peak hours, which end at EndOffPeakTime.
+	OffPeakChargingEnabled      bool      `json:"off_peak_charging_enabled"`
+	OffPeakChargingWeekdaysOnly bool      `json:"off_peak_charging_weekdays_only"`
+	EndOffPeakTime              TimeOfDay `json:"end_off_peak_time"`
+}
+
+// SetScheduledDeparture sets the departure time the vehicle prepares for, by
+// preconditioning and by charging during off-peak hours.
+func (v *Vehicle) SetScheduledDeparture(ctx context.Context, req ScheduledDepartureRequest) error {
+	if err := req.DepartureTime.validate(); err != nil {
+		return err
+	}
+	if err := req.EndOffPeakTime.validate(); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, "set_scheduled_departure", &req)
+}
+
+// ChargeSchedule is a charging window at a location. The schedule applies when
+// the vehicle is at Lat and Lon, which default to its current location.
+type ChargeSchedule struct {
+	// ID selects an existing schedule to replace. Leave zero to add a schedule.
+	ID           uint64     `json:"id,omitempty"`
+	DaysOfWeek   DaysOfWeek `json:"days_of_week"`
+	Enabled      bool       `json:"enabled"`
+	StartEnabled bool       `json:"start_enabled"`
+	StartTime    TimeOfDay  `json:"start_time"`
+	EndEnabled   bool       `json:"end_enabled"`
+	EndTime      TimeOfDay  `json:"end_time"`
+	OneTime      bool       `json:"one_time"`
+	Lat          float64    `json:"lat"`
+	Lon          float64    `json:"lon"`
+}
+
+// AddChargeSchedule adds a charge schedule, or replaces the one with the same ID.
+func (v *Vehicle) AddChargeSchedule(ctx context.Context, s ChargeSchedule) error {
+	if err := s.StartTime.validate(); err != nil {
+		return err
+	}
+	if err := s.EndTime.validate(); err != nil {
+		return err
+	}
+	if err := v.scheduleLocation(ctx, &s.Lat, &s.Lon); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, "add_charge_schedule", &s)
+}
+
+// RemoveChargeSchedule removes the charge schedule with the given ID.
+func (v *Vehicle) RemoveChargeSchedule(ctx context.Context, id uint64) error {
+	return v.sendCommandRequest(ctx, "remove_charge_schedule", &scheduleIDRequest{ID: id})
+}
+
+// PreconditionSchedule is a departure time the cabin is preconditioned for. The
+// schedule applies when the vehicle is at Lat and Lon, which default to its
+// current location.
+type PreconditionSchedule struct {
+	// ID selects an existing schedule to replace. Leave zero to add a schedule.
+	ID               uint64     `json:"id,omitempty"`
+	DaysOfWeek       DaysOfWeek `json:"days_of_week"`
+	Enabled          bool       `json:"enabled"`
+	PreconditionTime TimeOfDay  `json:"precondition_time"`
+	OneTime          bool       `json:"one_time"`
+	Lat              float64    `json:"lat"`
+	Lon              float64    `json:"lon"`
+}
+
+// AddPreconditionSchedule adds a precondition schedule, or replaces the one with the same ID.
+func (v *Vehicle) AddPreconditionSchedule(ctx context.Context, s PreconditionSchedule) error {
+	if err := s.PreconditionTime.validate(); err != nil {
+		return err
+	}
+	if err := v.scheduleLocation(ctx, &s.Lat, &s.Lon); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, "add_precondition_schedule", &s)
+}
+
+// RemovePreconditionSchedule removes the precondition schedule with the given ID.
+func (v *Vehicle) RemovePreconditionSchedule(ctx context.Context, id uint64) error {
+	return v.sendCommandRequest(ctx, "remove_precondition_schedule", &scheduleIDRequest{ID: id})
+}
+
+type scheduleIDRequest struct {
+	ID uint64 `json:"id"`
+}
+
+// Fills in the current location of the vehicle when no location is given
+func (v *Vehicle) scheduleLocation(ctx context.Context, lat, lon *float64) error {
+	if *lat != 0 || *lon != 0 {
+		return nil
+	}
+	var err error
+	*lat, *lon, err = v.location(ctx)
+	return err
+}
@@ -0,0 +1,78 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScheduleSpec(t *testing.T) {
+	ts := serveHTTP(t)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+	ctx := context.Background()
+
+	Convey("Should encode times of day as minutes", t, func() {
+		So(At(7, 30).Minutes(), ShouldEqual, 450)
+		So(At(7, 5).String(), ShouldEqual, "07:05")
+		So(TimeOfDay(90*time.Minute), ShouldEqual, At(1, 30))
+
+		var tod TimeOfDay
+		So(json.Unmarshal([]byte("1320"), &tod), ShouldBeNil)
+		So(tod, ShouldEqual, At(22, 0))
+
+		So(Days(time.Monday, time.Friday), ShouldEqual, DaysOfWeek("Monday,Friday"))
+	})
+
+	Convey("Should set scheduled charging", t, func() {
+		So(vehicle.SetScheduledCharging(ctx, true, At(1, 30)), ShouldBeNil)
+		So(vehicle.SetScheduledCharging(ctx, true, At(24, 0)), ShouldNotBeNil)
+	})
+
+	Convey("Should set scheduled departure", t, func() {
+		err := vehicle.SetScheduledDeparture(ctx, ScheduledDepartureRequest{
+			Enable:                      true,
+			DepartureTime:               At(7, 30),
+			PreconditioningEnabled:      true,
+			PreconditioningWeekdaysOnly: true,
+			OffPeakChargingEnabled:      true,
+			EndOffPeakTime:              At(6, 0),
+		})
+		So(err, ShouldBeNil)
+		So(vehicle.SetScheduledDeparture(ctx, ScheduledDepartureRequest{DepartureTime: -1}), ShouldNotBeNil)
+	})
+
+	Convey("Should manage charge schedules at the current location", t, func() {
+		err := vehicle.AddChargeSchedule(ctx, ChargeSchedule{
+			DaysOfWeek:   Days(time.Monday, time.Friday),
+			Enabled:      true,
+			StartEnabled: true,
+			StartTime:    At(22, 0),
+		})
+		So(err, ShouldBeNil)
+		So(vehicle.RemoveChargeSchedule(ctx, 7), ShouldBeNil)
+	})
+
+	Convey("Should manage precondition schedules", t, func() {
+		err := vehicle.AddPreconditionSchedule(ctx, PreconditionSchedule{
+			ID:               3,
+			DaysOfWeek:       Weekdays,
+			Enabled:          true,
+			PreconditionTime: At(8, 0),
+			OneTime:          true,
+			Lat:              1.5,
+			Lon:              2.5,
+		})
+		So(err, ShouldBeNil)
+		So(vehicle.RemovePreconditionSchedule(ctx, 3), ShouldBeNil)
+	})
+}
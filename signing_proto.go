@@ -0,0 +1,389 @@
+package tesla
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal protobuf wire format support for the messages of the vehicle command
+// protocol. Field numbers follow universal_message.proto, signatures.proto,
+// car_server.proto and vcsec.proto from Tesla's vehicle-command repository.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+// protoWriter appends protobuf fields to a buffer. Scalar fields holding their
+// default value are omitted, as proto3 encoders do.
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wire int) {
+	w.uvarint(uint64(field)<<3 | uint64(wire))
+}
+
+func (w *protoWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf = append(w.buf, b[:n]...)
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, wireVarint)
+	w.uvarint(v)
+}
+
+func (w *protoWriter) bool(field int, v bool) {
+	if v {
+		w.varint(field, 1)
+	}
+}
+
+func (w *protoWriter) fixed32(field int, v uint32) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, wireFixed32)
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *protoWriter) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.message(field, v)
+}
+
+// message writes a length-delimited field even when it is empty, which is how
+// oneof members without fields are selected.
+func (w *protoWriter) message(field int, v []byte) {
+	w.tag(field, wireBytes)
+	w.uvarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// protoField is a decoded field. For varint and fixed fields the value is in
+// num, for length-delimited fields in data.
+type protoField struct {
+	num  uint64
+	data []byte
+}
+
+// protoFields maps field numbers to their last occurrence in a message.
+type protoFields map[int]protoField
+
+func parseProto(b []byte) (protoFields, error) {
+	fields := protoFields{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errProtoTruncated
+		}
+		b = b[n:]
+		field, wire := int(key>>3), int(key&7)
+
+		var f protoField
+		switch wire {
+		case wireVarint:
+			f.num, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errProtoTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errProtoTruncated
+			}
+			f.num, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errProtoTruncated
+			}
+			f.num, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errProtoTruncated
+			}
+			f.data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d", wire)
+		}
+		fields[field] = f
+	}
+	return fields, nil
+}
+
+func (f protoFields) has(field int) bool {
+	_, ok := f[field]
+	return ok
+}
+
+func (f protoFields) uint32(field int) uint32 {
+	return uint32(f[field].num)
+}
+
+func (f protoFields) bytes(field int) []byte {
+	return f[field].data
+}
+
+func (f protoFields) message(field int) (protoFields, error) {
+	return parseProto(f[field].data)
+}
+
+// routableMessage is the envelope of every message exchanged with the vehicle.
+type routableMessage struct {
+	ToDomain           Domain
+	FromAddress        []byte
+	Payload            []byte
+	SessionInfoRequest *sessionInfoRequest
+	SessionInfo        []byte
+	Signature          *signatureData
+	Status             *messageStatus
+	Flags              uint32
+	// UUID identifies a request, RequestUUID the request a reply answers.
+	UUID        []byte
+	RequestUUID []byte
+}
+
+type sessionInfoRequest struct {
+	PublicKey []byte
+	Challenge []byte
+}
+
+type messageStatus struct {
+	OperationStatus uint32
+	Fault           MessageFault
+}
+
+type signatureData struct {
+	SignerPublicKey []byte
+	GCM             *gcmSignature
+	SessionInfoTag  []byte
+	HMAC            *hmacSignature
+}
+
+type gcmSignature struct {
+	Epoch     []byte
+	Nonce     []byte
+	Counter   uint32
+	ExpiresAt uint32
+	Tag       []byte
+}
+
+type hmacSignature struct {
+	Epoch     []byte
+	Counter   uint32
+	ExpiresAt uint32
+	Tag       []byte
+}
+
+// sessionInfo is the vehicle's half of the session handshake.
+type sessionInfo struct {
+	Counter   uint32
+	PublicKey []byte
+	Epoch     []byte
+	ClockTime uint32
+	Status    uint32
+}
+
+func (m *routableMessage) marshal() []byte {
+	var w protoWriter
+	if m.ToDomain != 0 {
+		var d protoWriter
+		d.varint(1, uint64(m.ToDomain))
+		w.message(6, d.buf)
+	}
+	if m.FromAddress != nil {
+		var d protoWriter
+		d.bytes(2, m.FromAddress)
+		w.message(7, d.buf)
+	}
+	w.bytes(10, m.Payload)
+	if m.Status != nil {
+		var s protoWriter
+		s.varint(1, uint64(m.Status.OperationStatus))
+		s.varint(2, uint64(m.Status.Fault))
+		w.message(12, s.buf)
+	}
+	if m.Signature != nil {
+		w.message(13, m.Signature.marshal())
+	}
+	if m.SessionInfoRequest != nil {
+		var r protoWriter
+		r.bytes(1, m.SessionInfoRequest.PublicKey)
+		r.bytes(2, m.SessionInfoRequest.Challenge)
+		w.message(14, r.buf)
+	}
+	w.bytes(15, m.SessionInfo)
+	w.bytes(50, m.RequestUUID)
+	w.bytes(51, m.UUID)
+	w.varint(52, uint64(m.Flags))
+	return w.buf
+}
+
+func (m *routableMessage) unmarshal(b []byte) error {
+	f, err := parseProto(b)
+	if err != nil {
+		return err
+	}
+	*m = routableMessage{
+		Payload:     f.bytes(10),
+		SessionInfo: f.bytes(15),
+		RequestUUID: f.bytes(50),
+		UUID:        f.bytes(51),
+		Flags:       f.uint32(52),
+	}
+	if f.has(6) {
+		d, err := f.message(6)
+		if err != nil {
+			return err
+		}
+		m.ToDomain = Domain(d.uint32(1))
+	}
+	if f.has(7) {
+		d, err := f.message(7)
+		if err != nil {
+			return err
+		}
+		m.FromAddress = d.bytes(2)
+	}
+	if f.has(12) {
+		s, err := f.message(12)
+		if err != nil {
+			return err
+		}
+		m.Status = &messageStatus{OperationStatus: s.uint32(1), Fault: MessageFault(s.uint32(2))}
+	}
+	if f.has(13) {
+		m.Signature = &signatureData{}
+		if err := m.Signature.unmarshal(f.bytes(13)); err != nil {
+			return err
+		}
+	}
+	if f.has(14) {
+		r, err := f.message(14)
+		if err != nil {
+			return err
+		}
+		m.SessionInfoRequest = &sessionInfoRequest{PublicKey: r.bytes(1), Challenge: r.bytes(2)}
+	}
+	return nil
+}
+
+func (s *signatureData) marshal() []byte {
+	var w protoWriter
+	if s.SignerPublicKey != nil {
+		var k protoWriter
+		k.bytes(1, s.SignerPublicKey)
+		w.message(1, k.buf)
+	}
+	if s.GCM != nil {
+		var g protoWriter
+		g.bytes(1, s.GCM.Epoch)
+		g.bytes(2, s.GCM.Nonce)
+		g.varint(3, uint64(s.GCM.Counter))
+		g.fixed32(4, s.GCM.ExpiresAt)
+		g.bytes(5, s.GCM.Tag)
+		w.message(5, g.buf)
+	}
+	if s.SessionInfoTag != nil {
+		var t protoWriter
+		t.bytes(1, s.SessionInfoTag)
+		w.message(6, t.buf)
+	}
+	if s.HMAC != nil {
+		var h protoWriter
+		h.bytes(1, s.HMAC.Epoch)
+		h.varint(2, uint64(s.HMAC.Counter))
+		h.fixed32(3, s.HMAC.ExpiresAt)
+		h.bytes(4, s.HMAC.Tag)
+		w.message(8, h.buf)
+	}
+	return w.buf
+}
+
+func (s *signatureData) unmarshal(b []byte) error {
+	f, err := parseProto(b)
+	if err != nil {
+		return err
+	}
+	*s = signatureData{}
+	if f.has(1) {
+		k, err := f.message(1)
+		if err != nil {
+			return err
+		}
+		s.SignerPublicKey = k.bytes(1)
+	}
+	if f.has(5) {
+		g, err := f.message(5)
+		if err != nil {
+			return err
+		}
+		s.GCM = &gcmSignature{
+			Epoch:     g.bytes(1),
+			Nonce:     g.bytes(2),
+			Counter:   g.uint32(3),
+			ExpiresAt: g.uint32(4),
+			Tag:       g.bytes(5),
+		}
+	}
+	if f.has(6) {
+		t, err := f.message(6)
+		if err != nil {
+			return err
+		}
+		s.SessionInfoTag = t.bytes(1)
+	}
+	if f.has(8) {
+		h, err := f.message(8)
+		if err != nil {
+			return err
+		}
+		s.HMAC = &hmacSignature{
+			Epoch:     h.bytes(1),
+			Counter:   h.uint32(2),
+			ExpiresAt: h.uint32(3),
+			Tag:       h.bytes(4),
+		}
+	}
+	return nil
+}
+
+func (s *sessionInfo) marshal() []byte {
+	var w protoWriter
+	w.varint(1, uint64(s.Counter))
+	w.bytes(2, s.PublicKey)
+	w.bytes(3, s.Epoch)
+	w.fixed32(4, s.ClockTime)
+	w.varint(5, uint64(s.Status))
+	return w.buf
+}
+
+func (s *sessionInfo) unmarshal(b []byte) error {
+	f, err := parseProto(b)
+	if err != nil {
+		return err
+	}
+	*s = sessionInfo{
+		Counter:   f.uint32(1),
+		PublicKey: f.bytes(2),
+		Epoch:     f.bytes(3),
+		ClockTime: f.uint32(4),
+		Status:    f.uint32(5),
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SoftwareUpdateStatus is the stage of a software update of the vehicle.
+type SoftwareUpdateStatus string
+
+const (
+	// SoftwareUpdateNone means no update is pending.
+	SoftwareUpdateNone        SoftwareUpdateStatus = ""
+	SoftwareUpdateAvailable   SoftwareUpdateStatus = "available"
+	SoftwareUpdateScheduled   SoftwareUpdateStatus = "scheduled"
+	SoftwareUpdateDownloading SoftwareUpdateStatus = "downloading"
+	// SoftwareUpdateWifiWait means the download waits for a Wi-Fi connection.
+	SoftwareUpdateWifiWait   SoftwareUpdateStatus = "downloading_wifi_wait"
+	SoftwareUpdateInstalling SoftwareUpdateStatus = "installing"
+)
+
+// InProgress reports whether the update is scheduled, downloading or installing.
+func (s SoftwareUpdateStatus) InProgress() bool {
+	switch s {
+	case SoftwareUpdateScheduled, SoftwareUpdateDownloading, SoftwareUpdateWifiWait, SoftwareUpdateInstalling:
+		return true
+	}
+	return false
+}
+
+// SoftwareUpdate contains the state of a pending software update.
+type SoftwareUpdate struct {
+	DownloadPerc           int                  `json:"download_perc"`
+	ExpectedDurationSec    int                  `json:"expected_duration_sec"`
+	InstallPerc            int                  `json:"install_perc"`
+	Status                 SoftwareUpdateStatus `json:"status"`
+	Version                string               `json:"version"`
+	ScheduledTimeMs        int64                `json:"scheduled_time_ms"`
+	WarningTimeRemainingMs int64                `json:"warning_time_remaining_ms"`
+}
+
+// ScheduleSoftwareUpdateRequest is the body of the schedule_software_update command.
+type ScheduleSoftwareUpdateRequest struct {
+	OffsetSec int `json:"offset_sec"`
+}
+
+// ScheduleSoftwareUpdate schedules the available software update to install after offset.
+func (v *Vehicle) ScheduleSoftwareUpdate(ctx context.Context, offset time.Duration) error {
+	if offset < 0 {
+		return fmt.Errorf("negative software update offset %v", offset)
+	}
+	return v.sendCommandRequest(ctx, "schedule_software_update", &ScheduleSoftwareUpdateRequest{OffsetSec: int(offset / time.Second)})
+}
+
+// CancelSoftwareUpdate cancels a scheduled software update.
+func (v *Vehicle) CancelSoftwareUpdate(ctx context.Context) error {
+	_, err := v.sendCommand(ctx, v.commandPath("cancel_software_update"), nil)
+	return err
+}
+
+// SoftwareUpdate fetches the state of the pending software update.
+func (v *Vehicle) SoftwareUpdate(ctx context.Context) (*SoftwareUpdate, error) {
+	data, err := v.DataFor(ctx, DataEndpointVehicleState)
+	if err != nil {
+		return nil, err
+	}
+	if data.Response.VehicleState == nil {
+		return nil, errors.New("vehicle did not report its vehicle state")
+	}
+	return &data.Response.VehicleState.SoftwareUpdate, nil
+}
+
+// TrackSoftwareUpdate polls the software update every interval, passing each
+// state to progress, until it is no longer in progress. A scheduled update is
+// not polled again before its scheduled time, so the vehicle may sleep until
+// then. Polls that fail because the vehicle is unavailable, as it is while
+// installing, are skipped. It returns the final state.
+func (v *Vehicle) TrackSoftwareUpdate(ctx context.Context, interval time.Duration, progress func(SoftwareUpdate)) (*SoftwareUpdate, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	for {
+		wait := interval
+		update, err := v.SoftwareUpdate(ctx)
+		switch {
+		case errors.Is(err, ErrVehicleUnavailable):
+		case err != nil:
+			return nil, err
+		default:
+			if progress != nil {
+				progress(*update)
+			}
+			if !update.Status.InProgress() {
+				return update, nil
+			}
+			if update.Status == SoftwareUpdateScheduled && update.ScheduledTimeMs > 0 {
+				if d := time.Until(time.UnixMilli(update.ScheduledTimeMs)); d > wait {
+					wait = d
+				}
+			}
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
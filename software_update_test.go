@@ -0,0 +1,109 @@
+package tesla
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// updatingVehicle serves a software update that progresses with every poll.
+func updatingVehicle(polls *int32) *http.ServeMux {
+	stages := []string{
+		`{"status":"scheduled","version":"2024.8.7","scheduled_time_ms":1700000000000}`,
+		`{"status":"downloading","download_perc":50,"version":"2024.8.7"}`,
+		`{"status":"installing","download_perc":100,"install_perc":30,"version":"2024.8.7"}`,
+		``,
+		`{"status":""}`,
+	}
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+		n := int(atomic.AddInt32(polls, 1)) - 1
+		if n >= len(stages) {
+			n = len(stages) - 1
+		}
+		if stages[n] == "" {
+			serveStatus(http.StatusRequestTimeout, VehicleUnavailableJSON)(w, req)
+			return
+		}
+		serveJSON(`{"response":{"vehicle_state":{"software_update":`+stages[n]+`}}}`)(w, req)
+	})
+	return mux
+}
+
+func TestSoftwareUpdateSpec(t *testing.T) {
+	ts := serveHTTP(t)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+	ctx := context.Background()
+
+	Convey("Should schedule and cancel software updates", t, func() {
+		So(vehicle.ScheduleSoftwareUpdate(ctx, 2*time.Hour), ShouldBeNil)
+		So(vehicle.CancelSoftwareUpdate(ctx), ShouldBeNil)
+		So(vehicle.ScheduleSoftwareUpdate(ctx, -time.Second), ShouldNotBeNil)
+	})
+
+	Convey("Should track the update until it is installed", t, func() {
+		var polls int32
+		ts := httptest.NewServer(updatingVehicle(&polls))
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		var seen []string
+		update, err := vehicles[0].TrackSoftwareUpdate(ctx, time.Millisecond, func(u SoftwareUpdate) {
+			seen = append(seen, string(u.Status))
+		})
+		So(err, ShouldBeNil)
+		So(update.Status, ShouldEqual, SoftwareUpdateNone)
+		So(strings.Join(seen, ","), ShouldEqual, "scheduled,downloading,installing,")
+		So(atomic.LoadInt32(&polls), ShouldEqual, 5)
+	})
+
+	Convey("Should not poll a scheduled update before its scheduled time", t, func() {
+		var polls int32
+		mux := new(http.ServeMux)
+		mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+		mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&polls, 1)
+			scheduled := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+			serveJSON(fmt.Sprintf(`{"response":{"vehicle_state":{"software_update":{"status":"scheduled","scheduled_time_ms":%d}}}}`, scheduled))(w, req)
+		})
+		ts := httptest.NewServer(mux)
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = vehicles[0].TrackSoftwareUpdate(ctx, time.Millisecond, nil)
+		So(err, ShouldEqual, context.DeadlineExceeded)
+		So(atomic.LoadInt32(&polls), ShouldEqual, 1)
+	})
+
+	Convey("Should stop tracking when the context ends", t, func() {
+		var polls int32
+		ts := httptest.NewServer(updatingVehicle(&polls))
+		defer ts.Close()
+		vehicles, err := NewTestClient(ts).Vehicles()
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err = vehicles[0].TrackSoftwareUpdate(ctx, time.Hour, nil)
+		So(err, ShouldEqual, context.DeadlineExceeded)
+	})
+}
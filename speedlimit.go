@@ -0,0 +1,103 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Limits of speed limit mode, used when the vehicle does not report its own.
+const (
+	DefaultMinSpeedLimitMph = 50
+	DefaultMaxSpeedLimitMph = 90
+)
+
+// SpeedLimitPINRequest is the body of the speed limit commands that take a PIN.
+type SpeedLimitPINRequest struct {
+	PIN string `json:"pin"`
+}
+
+// SpeedLimitActivate turns speed limit mode on, protected by the 4 digit pin.
+func (v *Vehicle) SpeedLimitActivate(ctx context.Context, pin string) error {
+	return v.speedLimitPINCommand(ctx, "speed_limit_activate", pin)
+}
+
+// SpeedLimitDeactivate turns speed limit mode off.
+func (v *Vehicle) SpeedLimitDeactivate(ctx context.Context, pin string) error {
+	return v.speedLimitPINCommand(ctx, "speed_limit_deactivate", pin)
+}
+
+// SpeedLimitClearPIN clears the speed limit PIN.
+func (v *Vehicle) SpeedLimitClearPIN(ctx context.Context, pin string) error {
+	return v.speedLimitPINCommand(ctx, "speed_limit_clear_pin", pin)
+}
+
+// SpeedLimitRequest is the body of the speed_limit_set_limit command.
+type SpeedLimitRequest struct {
+	LimitMph float64 `json:"limit_mph"`
+}
+
+// SpeedLimitSetLimit sets the maximum speed of speed limit mode. The limit must
+// be within the MinLimitMph and MaxLimitMph reported by the vehicle.
+func (v *Vehicle) SpeedLimitSetLimit(ctx context.Context, mph float64) error {
+	data, err := v.DataFor(ctx, DataEndpointVehicleState)
+	if err != nil {
+		return err
+	}
+	if data.Response.VehicleState == nil {
+		return errors.New("vehicle did not report its vehicle state")
+	}
+	min, max := data.Response.VehicleState.SpeedLimitMode.limits()
+	if mph < min || mph > max {
+		return fmt.Errorf("speed limit %g mph is not between %g and %g mph", mph, min, max)
+	}
+	return v.sendCommandRequest(ctx, "speed_limit_set_limit", &SpeedLimitRequest{LimitMph: mph})
+}
+
+func (m SpeedLimitMode) limits() (min, max float64) {
+	min, max = m.MinLimitMph, m.MaxLimitMph
+	if min == 0 {
+		min = DefaultMinSpeedLimitMph
+	}
+	if max == 0 {
+		max = DefaultMaxSpeedLimitMph
+	}
+	return min, max
+}
+
+// ValetModeRequest is the body of the set_valet_mode command.
+type ValetModeRequest struct {
+	On       bool   `json:"on"`
+	Password string `json:"password,omitempty"`
+}
+
+// SetValetMode turns valet mode on or off. The 4 digit pin is needed to turn it
+// off again and may be empty to use the PIN already set, see ResetValetPIN.
+func (v *Vehicle) SetValetMode(ctx context.Context, on bool, pin string) error {
+	if pin != "" {
+		if err := validatePIN(pin); err != nil {
+			return err
+		}
+	}
+	return v.sendCommandRequest(ctx, "set_valet_mode", &ValetModeRequest{On: on, Password: pin})
+}
+
+// Sends a speed limit command authorized with pin
+func (v *Vehicle) speedLimitPINCommand(ctx context.Context, command, pin string) error {
+	if err := validatePIN(pin); err != nil {
+		return err
+	}
+	return v.sendCommandRequest(ctx, command, &SpeedLimitPINRequest{PIN: pin})
+}
+
+func validatePIN(pin string) error {
+	if len(pin) != 4 {
+		return errors.New("PIN must have 4 digits")
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return errors.New("PIN must have 4 digits")
+		}
+	}
+	return nil
+}
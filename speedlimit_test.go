@@ -0,0 +1,40 @@
+package tesla
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpeedLimitSpec(t *testing.T) {
+	ts := serveHTTP(t)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicles, err := client.Vehicles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := vehicles[0]
+	ctx := context.Background()
+
+	Convey("Should manage speed limit mode", t, func() {
+		So(vehicle.SpeedLimitActivate(ctx, "1234"), ShouldBeNil)
+		So(vehicle.SpeedLimitDeactivate(ctx, "1234"), ShouldBeNil)
+		So(vehicle.SpeedLimitClearPIN(ctx, "1234"), ShouldBeNil)
+		So(vehicle.SpeedLimitActivate(ctx, "12a4"), ShouldNotBeNil)
+		So(vehicle.SpeedLimitActivate(ctx, "123"), ShouldNotBeNil)
+	})
+
+	Convey("Should set the speed limit within the reported range", t, func() {
+		So(vehicle.SpeedLimitSetLimit(ctx, 70), ShouldBeNil)
+		So(vehicle.SpeedLimitSetLimit(ctx, 49), ShouldNotBeNil)
+		So(vehicle.SpeedLimitSetLimit(ctx, 88), ShouldNotBeNil)
+	})
+
+	Convey("Should set valet mode", t, func() {
+		So(vehicle.SetValetMode(ctx, true, "0042"), ShouldBeNil)
+		So(vehicle.SetValetMode(ctx, true, "42"), ShouldNotBeNil)
+	})
+}
@@ -1,7 +1,9 @@
 package tesla
 
 import (
-	"fmt"
+	"context"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -66,36 +68,44 @@ type ChargeState struct {
 
 // ClimateState contains the current climate states availale from the vehicle.
 type ClimateState struct {
-	Timestamp                  timeMsec    `json:"timestamp"`
-	InsideTemp                 float64     `json:"inside_temp"`
-	OutsideTemp                float64     `json:"outside_temp"`
-	DriverTempSetting          float64     `json:"driver_temp_setting"`
-	PassengerTempSetting       float64     `json:"passenger_temp_setting"`
-	LeftTempDirection          float64     `json:"left_temp_direction"`
-	RightTempDirection         float64     `json:"right_temp_direction"`
-	IsAutoConditioningOn       bool        `json:"is_auto_conditioning_on"`
-	IsFrontDefrosterOn         bool        `json:"is_front_defroster_on"`
-	IsRearDefrosterOn          bool        `json:"is_rear_defroster_on"`
-	FanStatus                  interface{} `json:"fan_status"`
-	IsClimateOn                bool        `json:"is_climate_on"`
-	MinAvailTemp               float64     `json:"min_avail_temp"`
-	MaxAvailTemp               float64     `json:"max_avail_temp"`
-	SeatHeaterLeft             int         `json:"seat_heater_left"`
-	SeatHeaterRight            int         `json:"seat_heater_right"`
-	SeatHeaterRearLeft         int         `json:"seat_heater_rear_left"`
-	SeatHeaterRearRight        int         `json:"seat_heater_rear_right"`
-	SeatHeaterRearCenter       int         `json:"seat_heater_rear_center"`
-	SeatHeaterRearRightBack    int         `json:"seat_heater_rear_right_back"`
-	SeatHeaterRearLeftBack     int         `json:"seat_heater_rear_left_back"`
-	SmartPreconditioning       bool        `json:"smart_preconditioning"`
-	BatteryHeater              bool        `json:"battery_heater"`
-	BatteryHeaterNoPower       interface{} `json:"battery_heater_no_power"`
-	ClimateKeeperMode          string      `json:"climate_keeper_mode"`
-	DefrostMode                int         `json:"defrost_mode"`
-	IsPreconditioning          bool        `json:"is_preconditioning"`
-	RemoteHeaterControlEnabled bool        `json:"remote_heater_control_enabled"`
-	SideMirrorHeaters          bool        `json:"side_mirror_heaters"`
-	WiperBladeHeater           bool        `json:"wiper_blade_heater"`
+	Timestamp                              timeMsec    `json:"timestamp"`
+	InsideTemp                             float64     `json:"inside_temp"`
+	OutsideTemp                            float64     `json:"outside_temp"`
+	DriverTempSetting                      float64     `json:"driver_temp_setting"`
+	PassengerTempSetting                   float64     `json:"passenger_temp_setting"`
+	LeftTempDirection                      float64     `json:"left_temp_direction"`
+	RightTempDirection                     float64     `json:"right_temp_direction"`
+	IsAutoConditioningOn                   bool        `json:"is_auto_conditioning_on"`
+	IsFrontDefrosterOn                     bool        `json:"is_front_defroster_on"`
+	IsRearDefrosterOn                      bool        `json:"is_rear_defroster_on"`
+	FanStatus                              interface{} `json:"fan_status"`
+	IsClimateOn                            bool        `json:"is_climate_on"`
+	MinAvailTemp                           float64     `json:"min_avail_temp"`
+	MaxAvailTemp                           float64     `json:"max_avail_temp"`
+	SeatHeaterLeft                         int         `json:"seat_heater_left"`
+	SeatHeaterRight                        int         `json:"seat_heater_right"`
+	SeatHeaterRearLeft                     int         `json:"seat_heater_rear_left"`
+	SeatHeaterRearRight                    int         `json:"seat_heater_rear_right"`
+	SeatHeaterRearCenter                   int         `json:"seat_heater_rear_center"`
+	SeatHeaterRearRightBack                int         `json:"seat_heater_rear_right_back"`
+	SeatHeaterRearLeftBack                 int         `json:"seat_heater_rear_left_back"`
+	SmartPreconditioning                   bool        `json:"smart_preconditioning"`
+	BatteryHeater                          bool        `json:"battery_heater"`
+	BatteryHeaterNoPower                   interface{} `json:"battery_heater_no_power"`
+	ClimateKeeperMode                      string      `json:"climate_keeper_mode"`
+	DefrostMode                            int         `json:"defrost_mode"`
+	IsPreconditioning                      bool        `json:"is_preconditioning"`
+	RemoteHeaterControlEnabled             bool        `json:"remote_heater_control_enabled"`
+	SideMirrorHeaters                      bool        `json:"side_mirror_heaters"`
+	WiperBladeHeater                       bool        `json:"wiper_blade_heater"`
+	BioweaponMode                          bool        `json:"bioweapon_mode"`
+	CabinOverheatProtection                string      `json:"cabin_overheat_protection"`
+	CabinOverheatProtectionActivelyCooling bool        `json:"cabin_overheat_protection_actively_cooling"`
+	CopActivationTemperature               string      `json:"cop_activation_temperature"`
+	AutoSeatClimateLeft                    bool        `json:"auto_seat_climate_left"`
+	AutoSeatClimateRight                   bool        `json:"auto_seat_climate_right"`
+	SeatFanFrontLeft                       int         `json:"seat_fan_front_left"`
+	SeatFanFrontRight                      int         `json:"seat_fan_front_right"`
 }
 
 // DriveState contains the current drive state of the vehicle.
@@ -129,69 +139,86 @@ type GuiSettings struct {
 
 // VehicleState contains the current state of the vehicle.
 type VehicleState struct {
-	Timestamp               timeMsec `json:"timestamp"`
-	APIVersion              int      `json:"api_version"`
-	AutoParkState           string   `json:"autopark_state"`
-	AutoParkStateV2         string   `json:"autopark_state_v2"`
-	AutoParkStateV3         string   `json:"autopark_state_v3"`
-	CalendarSupported       bool     `json:"calendar_supported"`
-	CarType                 string   `json:"car_type"`
-	CarVersion              string   `json:"car_version"`
-	CenterDisplayState      int      `json:"center_display_state"`
-	DarkRims                bool     `json:"dark_rims"`
-	DriverFrontDoor         int      `json:"df"`
-	DriverRearDoor          int      `json:"dr"`
-	ExteriorColor           string   `json:"exterior_color"`
-	FrontTrunk              int      `json:"ft"`
-	HasSpoiler              bool     `json:"has_spoiler"`
-	Locked                  bool     `json:"locked"`
-	NotificationsSupported  bool     `json:"notifications_supported"`
-	Odometer                float64  `json:"odometer"`
-	ParsedCalendarSupported bool     `json:"parsed_calendar_supported"`
-	PerfConfig              string   `json:"perf_config"`
-	PassengerFrontDoor      int      `json:"pf"`
-	PassengerRearDoor       int      `json:"pr"`
-	RearSeatHeaters         int      `json:"rear_seat_heaters"`
-	RemoteStart             bool     `json:"remote_start"`
-	RemoteStartSupported    bool     `json:"remote_start_supported"`
-	RightHandDrive          bool     `json:"rhd"`
-	RoofColor               string   `json:"roof_color"`
-	RearTrunk               int      `json:"rt"`
-	SentryMode              bool     `json:"sentry_mode"`
-	SentryModeAvailable     bool     `json:"sentry_mode_available"`
-	SeatType                int      `json:"seat_type"`
-	SpoilerType             string   `json:"spoiler_type"`
-	SunRoofInstalled        int      `json:"sun_roof_installed"`
-	SunRoofPercentOpen      int      `json:"sun_roof_percent_open"`
-	SunRoofState            string   `json:"sun_roof_state"`
-	ThirdRowSeats           string   `json:"third_row_seats"`
-	ValetMode               bool     `json:"valet_mode"`
-	VehicleName             string   `json:"vehicle_name"`
-	WheelType               string   `json:"wheel_type"`
-	FrontDriverWindow       int      `json:"fd_window"`
-	FrontPassengerWindow    int      `json:"fp_window"`
-	RearDriverWindow        int      `json:"rd_window"`
-	RearPassengerWindow     int      `json:"rp_window"`
-	IsUserPresent           bool     `json:"is_user_present"`
-	RemoteStartEnabled      bool     `json:"remote_start_enabled"`
-	ValetPinNeeded          bool     `json:"valet_pin_needed"`
-	MediaState              struct {
-		RemoteControlEnabled bool `json:"remote_control_enabled"`
-	} `json:"media_state"`
-	SoftwareUpdate struct {
-		DownloadPerc        int    `json:"download_perc"`
-		ExpectedDurationSec int    `json:"expected_duration_sec"`
-		InstallPerc         int    `json:"install_perc"`
-		Status              string `json:"status"`
-		Version             string `json:"version"`
-	} `json:"software_update" `
-	SpeedLimitMode struct {
-		Active          bool    `json:"active"`
-		CurrentLimitMph float64 `json:"current_limit_mph"`
-		MaxLimitMph     float64 `json:"max_limit_mph"`
-		MinLimitMph     float64 `json:"min_limit_mph"`
-		PinCodeSet      bool    `json:"pin_code_set"`
-	} `json:"speed_limit_mode"`
+	Timestamp               timeMsec       `json:"timestamp"`
+	APIVersion              int            `json:"api_version"`
+	AutoParkState           string         `json:"autopark_state"`
+	AutoParkStateV2         string         `json:"autopark_state_v2"`
+	AutoParkStateV3         string         `json:"autopark_state_v3"`
+	CalendarSupported       bool           `json:"calendar_supported"`
+	CarType                 string         `json:"car_type"`
+	CarVersion              string         `json:"car_version"`
+	CenterDisplayState      int            `json:"center_display_state"`
+	DarkRims                bool           `json:"dark_rims"`
+	DriverFrontDoor         int            `json:"df"`
+	DriverRearDoor          int            `json:"dr"`
+	ExteriorColor           string         `json:"exterior_color"`
+	FrontTrunk              int            `json:"ft"`
+	HasSpoiler              bool           `json:"has_spoiler"`
+	Locked                  bool           `json:"locked"`
+	NotificationsSupported  bool           `json:"notifications_supported"`
+	Odometer                float64        `json:"odometer"`
+	ParsedCalendarSupported bool           `json:"parsed_calendar_supported"`
+	PerfConfig              string         `json:"perf_config"`
+	PassengerFrontDoor      int            `json:"pf"`
+	PassengerRearDoor       int            `json:"pr"`
+	RearSeatHeaters         int            `json:"rear_seat_heaters"`
+	RemoteStart             bool           `json:"remote_start"`
+	RemoteStartSupported    bool           `json:"remote_start_supported"`
+	RightHandDrive          bool           `json:"rhd"`
+	RoofColor               string         `json:"roof_color"`
+	RearTrunk               int            `json:"rt"`
+	SentryMode              bool           `json:"sentry_mode"`
+	SentryModeAvailable     bool           `json:"sentry_mode_available"`
+	SeatType                int            `json:"seat_type"`
+	SpoilerType             string         `json:"spoiler_type"`
+	SunRoofInstalled        int            `json:"sun_roof_installed"`
+	SunRoofPercentOpen      int            `json:"sun_roof_percent_open"`
+	SunRoofState            string         `json:"sun_roof_state"`
+	ThirdRowSeats           string         `json:"third_row_seats"`
+	ValetMode               bool           `json:"valet_mode"`
+	VehicleName             string         `json:"vehicle_name"`
+	WheelType               string         `json:"wheel_type"`
+	FrontDriverWindow       int            `json:"fd_window"`
+	FrontPassengerWindow    int            `json:"fp_window"`
+	RearDriverWindow        int            `json:"rd_window"`
+	RearPassengerWindow     int            `json:"rp_window"`
+	IsUserPresent           bool           `json:"is_user_present"`
+	RemoteStartEnabled      bool           `json:"remote_start_enabled"`
+	ValetPinNeeded          bool           `json:"valet_pin_needed"`
+	MediaState              MediaState     `json:"media_state"`
+	MediaInfo               MediaInfo      `json:"media_info"`
+	SoftwareUpdate          SoftwareUpdate `json:"software_update"`
+	SpeedLimitMode          SpeedLimitMode `json:"speed_limit_mode"`
+}
+
+// MediaState contains whether the media player can be controlled remotely.
+type MediaState struct {
+	RemoteControlEnabled bool `json:"remote_control_enabled"`
+}
+
+// MediaInfo contains what the media player of the vehicle is playing.
+type MediaInfo struct {
+	A2DPSourceName       string  `json:"a2dp_source_name"`
+	AudioVolume          float64 `json:"audio_volume"`
+	AudioVolumeIncrement float64 `json:"audio_volume_increment"`
+	AudioVolumeMax       float64 `json:"audio_volume_max"`
+	MediaPlaybackStatus  string  `json:"media_playback_status"`
+	NowPlayingAlbum      string  `json:"now_playing_album"`
+	NowPlayingArtist     string  `json:"now_playing_artist"`
+	NowPlayingDuration   int     `json:"now_playing_duration"`
+	NowPlayingElapsed    int     `json:"now_playing_elapsed"`
+	NowPlayingSource     string  `json:"now_playing_source"`
+	NowPlayingStation    string  `json:"now_playing_station"`
+	NowPlayingTitle      string  `json:"now_playing_title"`
+}
+
+// SpeedLimitMode contains the speed limit settings of the vehicle.
+type SpeedLimitMode struct {
+	Active          bool    `json:"active"`
+	CurrentLimitMph float64 `json:"current_limit_mph"`
+	MaxLimitMph     float64 `json:"max_limit_mph"`
+	MinLimitMph     float64 `json:"min_limit_mph"`
+	PinCodeSet      bool    `json:"pin_code_set"`
 }
 
 // ServiceData contains the service data of the vehicle.
@@ -201,21 +228,58 @@ type ServiceData struct {
 	ServiceStatus string    `json:"service_status"`
 }
 
-// VehicleData represents the states of the vehicle.
+// DataEndpoint selects a section of the vehicle_data payload.
+type DataEndpoint string
+
+const (
+	DataEndpointChargeState   DataEndpoint = "charge_state"
+	DataEndpointClimateState  DataEndpoint = "climate_state"
+	DataEndpointDriveState    DataEndpoint = "drive_state"
+	DataEndpointGuiSettings   DataEndpoint = "gui_settings"
+	DataEndpointVehicleConfig DataEndpoint = "vehicle_config"
+	DataEndpointVehicleState  DataEndpoint = "vehicle_state"
+	// DataEndpointLocationData adds the location to the drive state on
+	// firmware that no longer reports it by default.
+	DataEndpointLocationData DataEndpoint = "location_data"
+	// DataEndpointClosuresState adds door, trunk and window states. Current
+	// firmware reports them in ClosuresState, older firmware in the vehicle state.
+	DataEndpointClosuresState DataEndpoint = "closures_state"
+)
+
+// VehicleData represents the states of the vehicle. Sections that were not
+// requested or not returned by the vehicle are nil.
 type VehicleData struct {
 	Response struct {
-		ChargeState   ChargeState   `json:"charge_state"`
-		ClimateState  ClimateState  `json:"climate_state"`
-		DriveState    DriveState    `json:"drive_state"`
-		VehicleState  VehicleState  `json:"vehicle_state"`
-		GuiSettings   GuiSettings   `json:"gui_settings"`
-		VehicleConfig VehicleConfig `json:"vehicle_config"`
+		ChargeState   *ChargeState   `json:"charge_state"`
+		ClimateState  *ClimateState  `json:"climate_state"`
+		DriveState    *DriveState    `json:"drive_state"`
+		VehicleState  *VehicleState  `json:"vehicle_state"`
+		GuiSettings   *GuiSettings   `json:"gui_settings"`
+		VehicleConfig *VehicleConfig `json:"vehicle_config"`
+		ClosuresState *ClosuresState `json:"closures_state"`
 		// ServiceData   ServiceData   `json:"service_data"`
 	} `json:"response"`
 	Error            string `json:"error"`
 	ErrorDescription string `json:"error_description"`
 }
 
+// ClosuresState contains the states of the doors, trunks and windows. Doors and
+// trunks are 0 when closed, windows 0 when fully closed.
+type ClosuresState struct {
+	DriverFrontDoor      int  `json:"df"`
+	DriverRearDoor       int  `json:"dr"`
+	PassengerFrontDoor   int  `json:"pf"`
+	PassengerRearDoor    int  `json:"pr"`
+	FrontTrunk           int  `json:"ft"`
+	RearTrunk            int  `json:"rt"`
+	FrontDriverWindow    int  `json:"fd_window"`
+	FrontPassengerWindow int  `json:"fp_window"`
+	RearDriverWindow     int  `json:"rd_window"`
+	RearPassengerWindow  int  `json:"rp_window"`
+	SunRoofPercentOpen   int  `json:"sun_roof_percent_open"`
+	Locked               bool `json:"locked"`
+}
+
 // MobileEnabledResponse is the response when a state is requested.
 type MobileEnabledResponse struct {
 	Bool bool `json:"response"`
@@ -223,8 +287,13 @@ type MobileEnabledResponse struct {
 
 // MobileEnabled returns if the vehicle is mobile enabled for Tesla API control
 func (v *Vehicle) MobileEnabled() (bool, error) {
+	return v.MobileEnabledContext(context.Background())
+}
+
+// MobileEnabledContext is like MobileEnabled but uses ctx for the request.
+func (v *Vehicle) MobileEnabledContext(ctx context.Context) (bool, error) {
 	r := &MobileEnabledResponse{}
-	if err := v.c.getJSON(v.c.baseURL+"/vehicles/"+strconv.FormatInt(v.ID, 10)+"/mobile_enabled", r); err != nil {
+	if err := v.c.getJSON(ctx, v.c.baseURL+"/vehicles/"+strconv.FormatInt(v.ID, 10)+"/mobile_enabled", r); err != nil {
 		return false, err
 	}
 	return r.Bool, nil
@@ -287,33 +356,48 @@ type NearbyChargingSitesResponse struct {
 
 // NearbyChargingSites returns the charging sites near the vehicle.
 func (v *Vehicle) NearbyChargingSites() (*NearbyChargingSitesResponse, error) {
+	return v.NearbyChargingSitesContext(context.Background())
+}
+
+// NearbyChargingSitesContext is like NearbyChargingSites but uses ctx for the request.
+func (v *Vehicle) NearbyChargingSitesContext(ctx context.Context) (*NearbyChargingSitesResponse, error) {
 	resp := &NearbyChargingSitesResponse{}
 	path := strings.Join([]string{v.c.baseURL, "vehicles", strconv.FormatInt(v.ID, 10), "nearby_charging_sites"}, "/")
-	if err := v.c.getJSON(path, resp); err != nil {
+	if err := v.c.getJSON(ctx, path, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
-func stateError(sr *VehicleData) error {
+func stateError(path string, sr *VehicleData) error {
 	if sr.Error == "" {
 		return nil
 	}
 
-	if sr.ErrorDescription != "" {
-		return fmt.Errorf("%s: %s", sr.Error, sr.ErrorDescription)
+	return &APIError{
+		StatusCode:       http.StatusOK,
+		Endpoint:         endpointPath(path),
+		ErrorCode:        sr.Error,
+		ErrorDescription: sr.ErrorDescription,
 	}
-	return fmt.Errorf("%s", sr.Error)
 }
 
-// A utility function to fetch the appropriate state of the vehicle
-func (c *Client) fetchState(id int64) (*VehicleData, error) {
+// A utility function to fetch the appropriate state of the vehicle, limited to
+// the given endpoints if any are provided
+func (c *Client) fetchState(ctx context.Context, id int64, endpoints []DataEndpoint) (*VehicleData, error) {
 	var res VehicleData
 	path := strings.Join([]string{c.baseURL, "vehicles", strconv.FormatInt(id, 10), "vehicle_data"}, "/")
-	if err := c.getJSON(path, &res); err != nil {
+	if len(endpoints) > 0 {
+		names := make([]string, len(endpoints))
+		for i, e := range endpoints {
+			names[i] = string(e)
+		}
+		path += "?" + url.Values{"endpoints": {strings.Join(names, ";")}}.Encode()
+	}
+	if err := c.getJSON(ctx, path, &res); err != nil {
 		return nil, err
 	}
-	if err := stateError(&res); err != nil {
+	if err := stateError(path, &res); err != nil {
 		return nil, err
 	}
 	return &res, nil
@@ -321,5 +405,22 @@ func (c *Client) fetchState(id int64) (*VehicleData, error) {
 
 // Data : Get data of the vehicle (calling this will not permit the car to sleep)
 func (v Vehicle) Data() (*VehicleData, error) {
-	return v.c.fetchState(v.ID)
+	return v.DataContext(context.Background())
+}
+
+// DataContext is like Data but uses ctx for the request.
+func (v Vehicle) DataContext(ctx context.Context) (*VehicleData, error) {
+	return v.DataFor(ctx)
+}
+
+// DataFor gets only the requested sections of the vehicle data, which keeps
+// payloads small when polling frequently. Sections that were not requested are
+// nil in the result. Without endpoints the full payload is returned, as with Data.
+func (v Vehicle) DataFor(ctx context.Context, endpoints ...DataEndpoint) (*VehicleData, error) {
+	var data *VehicleData
+	err := v.withWake(ctx, func() (err error) {
+		data, err = v.c.fetchState(ctx, v.ID, endpoints)
+		return err
+	})
+	return data, err
 }
@@ -1,6 +1,7 @@
 package tesla
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -16,7 +17,7 @@ var (
 	"climate_state": {"inside_temp":null,"outside_temp":null,"driver_temp_setting":22.0,"passenger_temp_setting":22.0,"left_temp_direction":17,"right_temp_direction":17,"is_auto_conditioning_on":null,"is_front_defroster_on":null,"is_rear_defroster_on":false,"fan_status":null,"is_climate_on":false,"min_avail_temp":15,"max_avail_temp":28,"seat_heater_left":0,"seat_heater_right":0,"seat_heater_rear_left":0,"seat_heater_rear_right":0,"seat_heater_rear_center":0,"seat_heater_rear_right_back":0,"seat_heater_rear_left_back":0,"smart_preconditioning":false},
 	"drive_state": {"shift_state":null,"speed":null,"latitude":35.1,"longitude":20.2,"heading":57,"gps_as_of":1452491619},
 	"gui_settings": {"gui_distance_units":"mi/hr","gui_temperature_units":"F","gui_charge_rate_units":"mi/hr","gui_24_hour_time":true,"gui_range_display":"Rated"},
-	"vehicle_state": {"api_version":3,"calendar_supported":true,"car_type":"s","car_version":"2.9.12","center_display_state":0,"dark_rims":false,"df":0,"dr":0,"exterior_color":"Black","ft":0,"has_spoiler":true,"locked":true,"notifications_supported":true,"odometer":3738.84633,"parsed_calendar_supported":true,"perf_config":"P2","pf":0,"pr":0,"rear_seat_heaters":1,"remote_start":false,"remote_start_supported":true,"rhd":false,"roof_color":"None","rt":0,"seat_type":1,"sun_roof_installed":2,"sun_roof_percent_open":0,"sun_roof_state":"unknown","third_row_seats":"None","valet_mode":false,"vehicle_name":"Macak","wheel_type":"Super21Gray"}
+	"vehicle_state": {"api_version":3,"calendar_supported":true,"car_type":"s","car_version":"2.9.12","center_display_state":0,"dark_rims":false,"df":0,"dr":0,"exterior_color":"Black","ft":0,"has_spoiler":true,"locked":true,"notifications_supported":true,"odometer":3738.84633,"parsed_calendar_supported":true,"perf_config":"P2","pf":0,"pr":0,"rear_seat_heaters":1,"remote_start":false,"remote_start_supported":true,"rhd":false,"roof_color":"None","rt":0,"seat_type":1,"sun_roof_installed":2,"sun_roof_percent_open":0,"sun_roof_state":"unknown","third_row_seats":"None","valet_mode":false,"vehicle_name":"Macak","wheel_type":"Super21Gray","speed_limit_mode":{"active":false,"current_limit_mph":65,"max_limit_mph":85,"min_limit_mph":50,"pin_code_set":true},"media_state":{"remote_control_enabled":true},"media_info":{"audio_volume":2.3333,"audio_volume_increment":0.333333,"audio_volume_max":10.333333,"media_playback_status":"Playing","now_playing_artist":"Daft Punk","now_playing_source":"Spotify","now_playing_title":"Contact"}}
 	}}`
 	// "service_data": {"service_etc": "2019-08-15T14:15:00+02:00", "service_status": "in_service"},
 )
@@ -74,6 +75,11 @@ func TestStatesSpec(t *testing.T) {
 		So(status.Response.VehicleState.APIVersion, ShouldEqual, 3)
 		So(status.Response.VehicleState.CalendarSupported, ShouldBeTrue)
 		So(status.Response.VehicleState.RearTrunk, ShouldEqual, 0)
+		So(status.Response.VehicleState.MediaState.RemoteControlEnabled, ShouldBeTrue)
+		So(status.Response.VehicleState.MediaInfo.NowPlayingTitle, ShouldEqual, "Contact")
+		So(status.Response.VehicleState.MediaInfo.NowPlayingArtist, ShouldEqual, "Daft Punk")
+		So(status.Response.VehicleState.MediaInfo.NowPlayingSource, ShouldEqual, "Spotify")
+		So(status.Response.VehicleState.MediaInfo.AudioVolumeMax, ShouldEqual, 10.333333)
 	})
 
 	// Convey("Should get service data", t, func() {
@@ -104,3 +110,38 @@ func TestStatesSpecError(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestDataForSpec(t *testing.T) {
+	var endpoints string
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+		endpoints = req.URL.Query().Get("endpoints")
+		serveJSON(`{"response":{"charge_state":{"battery_level":0},"drive_state":{"latitude":35.1,"longitude":20.2}}}`)(w, req)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	vehicle := &Vehicle{ID: 1234, c: NewTestClient(ts)}
+
+	Convey("Should request only the selected endpoints", t, func() {
+		data, err := vehicle.DataFor(context.Background(), DataEndpointChargeState, DataEndpointDriveState, DataEndpointLocationData)
+		So(err, ShouldBeNil)
+		So(endpoints, ShouldEqual, "charge_state;drive_state;location_data")
+		So(data.Response.DriveState.Latitude, ShouldEqual, 35.1)
+	})
+
+	Convey("Should tell requested zero values from missing sections", t, func() {
+		data, err := vehicle.DataFor(context.Background(), DataEndpointChargeState)
+		So(err, ShouldBeNil)
+		So(data.Response.ChargeState, ShouldNotBeNil)
+		So(data.Response.ChargeState.BatteryLevel, ShouldEqual, 0)
+		So(data.Response.ClimateState, ShouldBeNil)
+		So(data.Response.VehicleConfig, ShouldBeNil)
+	})
+
+	Convey("Should not send endpoints for the full payload", t, func() {
+		_, err := vehicle.Data()
+		So(err, ShouldBeNil)
+		So(endpoints, ShouldEqual, "")
+	})
+}
@@ -0,0 +1,244 @@
+package tesla
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// StreamColumns are the columns requested from the streaming API, in the order
+// they are returned in each data:update frame after the timestamp.
+var StreamColumns = []string{
+	"speed", "odometer", "soc", "elevation", "est_heading", "est_lat",
+	"est_lng", "power", "shift_state", "range", "est_range", "heading",
+}
+
+// StreamEvent is a single telemetry sample delivered by the streaming API.
+// Columns the vehicle leaves empty, such as speed while parked, decode as zero.
+type StreamEvent struct {
+	Timestamp  time.Time
+	Speed      float64
+	Odometer   float64
+	SOC        int
+	Elevation  int
+	EstHeading int
+	EstLat     float64
+	EstLng     float64
+	Power      int
+	ShiftState string
+	Range      int
+	EstRange   int
+	Heading    int
+}
+
+// StreamOptions controls how Stream reconnects. Zero values fall back to the
+// defaults documented on each field.
+type StreamOptions struct {
+	// ReconnectDelay is the wait between reconnection attempts after the
+	// vehicle disconnects or the connection drops. Defaults to 5s.
+	ReconnectDelay time.Duration
+	// OnError, if set, is called with every error that caused a reconnect.
+	OnError func(error)
+}
+
+// streamMessage is the JSON envelope used by the streaming API in both directions.
+type streamMessage struct {
+	MsgType   string `json:"msg_type"`
+	Token     string `json:"token,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	ErrorType string `json:"error_type,omitempty"`
+}
+
+// StreamError is reported through StreamOptions.OnError when the streaming API
+// sends a data:error frame, typically because the vehicle went to sleep.
+type StreamError struct {
+	ErrorType string
+	Value     string
+}
+
+func (e *StreamError) Error() string {
+	if e.ErrorType == "" {
+		return "stream error: " + e.Value
+	}
+	return fmt.Sprintf("stream error: %s: %s", e.ErrorType, e.Value)
+}
+
+// Stream subscribes to the streaming telemetry of the vehicle using the
+// client's OAuth token and delivers parsed events on the returned channel. The
+// connection is re-established whenever the vehicle disconnects or the socket
+// drops. The channel is closed once ctx is done. An error is returned only if
+// the first connection cannot be established.
+func (v *Vehicle) Stream(ctx context.Context, opts *StreamOptions) (<-chan *StreamEvent, error) {
+	o := StreamOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.ReconnectDelay <= 0 {
+		o.ReconnectDelay = 5 * time.Second
+	}
+
+	ws, err := v.subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *StreamEvent)
+	go func() {
+		defer close(events)
+		for {
+			err := v.readStream(ctx, ws, events)
+			ws.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			if o.OnError != nil {
+				o.OnError(err)
+			}
+			for {
+				if sleepContext(ctx, o.ReconnectDelay) != nil {
+					return
+				}
+				if ws, err = v.subscribe(ctx); err == nil {
+					break
+				}
+				if o.OnError != nil {
+					o.OnError(err)
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Dials the streaming API and sends the subscription for this vehicle.
+func (v *Vehicle) subscribe(ctx context.Context) (*websocket.Conn, error) {
+	tok, err := v.c.Token()
+	if err != nil {
+		return nil, err
+	}
+	location, err := v.streamPath()
+	if err != nil {
+		return nil, err
+	}
+	config, err := websocket.NewConfig(location, v.c.streamingURL)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := websocket.JSON.Send(ws, &streamMessage{
+		MsgType: "data:subscribe_oauth",
+		Token:   tok.AccessToken,
+		Value:   strings.Join(StreamColumns, ","),
+		Tag:     strconv.FormatUint(v.VehicleID, 10),
+	}); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Reads frames from ws until an error occurs, delivering updates on events.
+func (v *Vehicle) readStream(ctx context.Context, ws *websocket.Conn, events chan<- *StreamEvent) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		var msg streamMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return err
+		}
+		switch msg.MsgType {
+		case "data:update":
+			event, err := parseStreamEvent(msg.Value)
+			if err != nil {
+				return err
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case "data:error":
+			return &StreamError{ErrorType: msg.ErrorType, Value: msg.Value}
+		}
+	}
+}
+
+// Returns the websocket URL of the streaming API.
+func (v *Vehicle) streamPath() (string, error) {
+	u, err := url.Parse(v.c.streamingURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/streaming/"
+	return u.String(), nil
+}
+
+// Parses the comma-separated value of a data:update frame.
+func parseStreamEvent(value string) (*StreamEvent, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != len(StreamColumns)+1 {
+		return nil, fmt.Errorf("stream update has %d fields, want %d", len(fields), len(StreamColumns)+1)
+	}
+
+	var parseErr error
+	float := func(s string) float64 {
+		if s == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil && parseErr == nil {
+			parseErr = err
+		}
+		return f
+	}
+	integer := func(s string) int {
+		return int(float(s))
+	}
+
+	ms, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	event := &StreamEvent{
+		Timestamp:  time.UnixMilli(ms),
+		Speed:      float(fields[1]),
+		Odometer:   float(fields[2]),
+		SOC:        integer(fields[3]),
+		Elevation:  integer(fields[4]),
+		EstHeading: integer(fields[5]),
+		EstLat:     float(fields[6]),
+		EstLng:     float(fields[7]),
+		Power:      integer(fields[8]),
+		ShiftState: fields[9],
+		Range:      integer(fields[10]),
+		EstRange:   integer(fields[11]),
+		Heading:    integer(fields[12]),
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid stream update: %w", parseErr)
+	}
+	return event, nil
+}
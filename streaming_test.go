@@ -0,0 +1,105 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	StreamUpdateParked  = "1612345678901,,12345.6,80,100,180,37.1,-122.1,0,,200,190,181"
+	StreamUpdateDriving = "1612345679901,42,12345.7,79,101,182,37.2,-122.2,35,D,199,189,183"
+)
+
+// streamingStandIn serves the streaming protocol, disconnecting the vehicle
+// after the first update of the first connection.
+func streamingStandIn(t *testing.T, subscriptions *int32) *httptest.Server {
+	mux := new(http.ServeMux)
+	mux.Handle("/streaming/", websocket.Handler(func(ws *websocket.Conn) {
+		var sub streamMessage
+		if err := websocket.JSON.Receive(ws, &sub); err != nil {
+			t.Error(err)
+			return
+		}
+		if sub.MsgType != "data:subscribe_oauth" || sub.Token != "refresh" || sub.Tag != "456" {
+			_ = websocket.JSON.Send(ws, &streamMessage{MsgType: "data:error", ErrorType: "client_error", Value: "bad subscription"})
+			return
+		}
+		_ = websocket.JSON.Send(ws, &streamMessage{MsgType: "control:hello"})
+		if atomic.AddInt32(subscriptions, 1) == 1 {
+			_ = websocket.JSON.Send(ws, &streamMessage{MsgType: "data:update", Tag: sub.Tag, Value: StreamUpdateParked})
+			_ = websocket.JSON.Send(ws, &streamMessage{MsgType: "data:error", Tag: sub.Tag, ErrorType: "vehicle_disconnected"})
+			return
+		}
+		_ = websocket.JSON.Send(ws, &streamMessage{MsgType: "data:update", Tag: sub.Tag, Value: StreamUpdateDriving})
+		var msg streamMessage
+		_ = websocket.JSON.Receive(ws, &msg)
+	}))
+	return httptest.NewServer(mux)
+}
+
+func TestStreamingSpec(t *testing.T) {
+	var subscriptions int32
+	ts := streamingStandIn(t, &subscriptions)
+	defer ts.Close()
+
+	client := NewTestClient(ts)
+	vehicle := &Vehicle{ID: 1234, VehicleID: 456, c: client}
+
+	Convey("Should stream events and reconnect after a disconnect", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var streamErrs []error
+		events, err := vehicle.Stream(ctx, &StreamOptions{
+			ReconnectDelay: time.Millisecond,
+			OnError:        func(err error) { streamErrs = append(streamErrs, err) },
+		})
+		So(err, ShouldBeNil)
+
+		parked := <-events
+		So(parked.Timestamp.UnixMilli(), ShouldEqual, 1612345678901)
+		So(parked.Speed, ShouldEqual, 0)
+		So(parked.Odometer, ShouldEqual, 12345.6)
+		So(parked.SOC, ShouldEqual, 80)
+		So(parked.ShiftState, ShouldEqual, "")
+		So(parked.Heading, ShouldEqual, 181)
+
+		driving := <-events
+		So(driving.Speed, ShouldEqual, 42)
+		So(driving.Power, ShouldEqual, 35)
+		So(driving.ShiftState, ShouldEqual, "D")
+		So(driving.EstLat, ShouldEqual, 37.2)
+		So(driving.EstRange, ShouldEqual, 189)
+
+		var streamErr *StreamError
+		So(streamErrs, ShouldNotBeEmpty)
+		So(errors.As(streamErrs[0], &streamErr), ShouldBeTrue)
+		So(streamErr.ErrorType, ShouldEqual, "vehicle_disconnected")
+
+		cancel()
+		_, open := <-events
+		So(open, ShouldBeFalse)
+	})
+
+	Convey("Should build the websocket URL from the streaming URL", t, func() {
+		c := &Client{streamingURL: "https://streaming.vn.teslamotors.com"}
+		u, err := (&Vehicle{c: c}).streamPath()
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "wss://streaming.vn.teslamotors.com/streaming/")
+	})
+
+	Convey("Should reject malformed updates", t, func() {
+		_, err := parseStreamEvent("1612345678901,1,2")
+		So(err, ShouldNotBeNil)
+		_, err = parseStreamEvent("1612345678901,fast,12345.6,80,100,180,37.1,-122.1,0,,200,190,181")
+		So(err, ShouldNotBeNil)
+	})
+}
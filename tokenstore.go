@@ -0,0 +1,247 @@
+package tesla
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenNotFound is returned by a TokenStore that holds no token yet.
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenStore persists the OAuth token of a client. Tesla rotates refresh tokens,
+// so every refreshed token has to be saved to keep the login across restarts.
+type TokenStore interface {
+	// Load returns the stored token, or ErrTokenNotFound when there is none.
+	Load() (*oauth2.Token, error)
+	// Save replaces the stored token.
+	Save(*oauth2.Token) error
+}
+
+// MemoryTokenStore keeps the token in memory.
+type MemoryTokenStore struct {
+	mu  sync.Mutex
+	tok *oauth2.Token
+}
+
+// NewMemoryTokenStore returns a store holding tok, which may be nil.
+func NewMemoryTokenStore(tok *oauth2.Token) *MemoryTokenStore {
+	return &MemoryTokenStore{tok: tok}
+}
+
+// Load returns a copy of the stored token.
+func (s *MemoryTokenStore) Load() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == nil {
+		return nil, ErrTokenNotFound
+	}
+	tok := *s.tok
+	return &tok, nil
+}
+
+// Save stores a copy of tok.
+func (s *MemoryTokenStore) Save(tok *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := *tok
+	s.tok = &t
+	return nil
+}
+
+// FileTokenStore keeps the token as JSON in a file, in the format written by
+// cmd/login and read by WithTokenFile.
+type FileTokenStore struct {
+	Path string
+}
+
+// NewFileTokenStore returns a store for the token file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+// Load reads the token from the file.
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := readTokenFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeToken(b)
+}
+
+// Save atomically replaces the file with tok.
+func (s *FileTokenStore) Save(tok *oauth2.Token) error {
+	b, err := json.MarshalIndent(tok, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b)
+}
+
+// EncryptedFileTokenStore keeps the token in a file encrypted with AES-GCM.
+type EncryptedFileTokenStore struct {
+	Path string
+	aead cipher.AEAD
+}
+
+// NewEncryptedFileTokenStore returns a store for the token file at path,
+// encrypted with key, which must be 16, 24 or 32 bytes long.
+func NewEncryptedFileTokenStore(path string, key []byte) (*EncryptedFileTokenStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedFileTokenStore{Path: path, aead: aead}, nil
+}
+
+// Load reads and decrypts the token from the file.
+func (s *EncryptedFileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := readTokenFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	n := s.aead.NonceSize()
+	if len(b) < n {
+		return nil, errors.New("encrypted token file is truncated")
+	}
+	plain, err := s.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt token: %w", err)
+	}
+	return decodeToken(plain)
+}
+
+// Save encrypts tok and atomically replaces the file with it.
+func (s *EncryptedFileTokenStore) Save(tok *oauth2.Token) error {
+	plain, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, s.aead.Seal(nonce, nonce, plain, nil))
+}
+
+func readTokenFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTokenNotFound
+	}
+	return b, err
+}
+
+func decodeToken(b []byte) (*oauth2.Token, error) {
+	tok := new(oauth2.Token)
+	if err := json.Unmarshal(b, tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// Writes to a temporary file next to path and renames it over path, so readers
+// never see a partially written token.
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// storeTokenSource saves every new token of the wrapped source.
+type storeTokenSource struct {
+	src     oauth2.TokenSource
+	store   TokenStore
+	onToken func(*oauth2.Token)
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+// NewStoreTokenSource wraps src so that every token it returns which differs from
+// the previous one is saved to store and passed to onToken. Both store and
+// onToken may be nil. A token that cannot be saved is reported as an error and
+// saving is retried on the next call.
+func NewStoreTokenSource(src oauth2.TokenSource, store TokenStore, onToken func(*oauth2.Token)) oauth2.TokenSource {
+	return newStoreTokenSource(src, store, onToken, nil)
+}
+
+func newStoreTokenSource(src oauth2.TokenSource, store TokenStore, onToken func(*oauth2.Token), stored *oauth2.Token) *storeTokenSource {
+	return &storeTokenSource{src: src, store: store, onToken: onToken, last: stored}
+}
+
+// Token returns a token from the wrapped source, persisting it when it is new.
+func (s *storeTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && s.last.AccessToken == tok.AccessToken && s.last.RefreshToken == tok.RefreshToken {
+		return tok, nil
+	}
+	if s.store != nil {
+		if err := s.store.Save(tok); err != nil {
+			return nil, fmt.Errorf("save token: %w", err)
+		}
+	}
+	s.last = tok
+	if s.onToken != nil {
+		s.onToken(tok)
+	}
+	return tok, nil
+}
+
+// WithTokenStore loads the token from store, unless another token or login option
+// provides it, and saves every refreshed token back to store.
+func WithTokenStore(store TokenStore) ClientOption {
+	return func(c *Client) error {
+		c.tokenStore = store
+		return nil
+	}
+}
+
+// WithTokenHandler calls handler with every new token the client obtains, such as
+// after a refresh.
+func WithTokenHandler(handler func(*oauth2.Token)) ClientOption {
+	return func(c *Client) error {
+		c.onToken = handler
+		return nil
+	}
+}
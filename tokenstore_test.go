@@ -0,0 +1,148 @@
+package tesla
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStoreSpec(t *testing.T) {
+	tok := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenType:    "Bearer",
+		Expiry:       time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	Convey("Should keep tokens in memory", t, func() {
+		s := NewMemoryTokenStore(nil)
+		_, err := s.Load()
+		So(err, ShouldEqual, ErrTokenNotFound)
+
+		So(s.Save(tok), ShouldBeNil)
+		got, err := s.Load()
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, tok)
+		So(got, ShouldNotPointTo, tok)
+	})
+
+	Convey("Should keep tokens in a file", t, func() {
+		path := filepath.Join(t.TempDir(), "tokens", "tesla.token")
+		s := NewFileTokenStore(path)
+		_, err := s.Load()
+		So(err, ShouldEqual, ErrTokenNotFound)
+
+		So(s.Save(tok), ShouldBeNil)
+		got, err := s.Load()
+		So(err, ShouldBeNil)
+		So(got.RefreshToken, ShouldEqual, "refresh")
+		So(got.Expiry.Equal(tok.Expiry), ShouldBeTrue)
+
+		info, err := os.Stat(path)
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+
+		// the file stays readable by WithTokenFile
+		c := &Client{}
+		So(WithTokenFile(path)(c), ShouldBeNil)
+		So(c.token.AccessToken, ShouldEqual, "access")
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+	})
+
+	Convey("Should keep tokens in an encrypted file", t, func() {
+		path := filepath.Join(t.TempDir(), "tesla.token")
+		key := bytes.Repeat([]byte{7}, 32)
+		s, err := NewEncryptedFileTokenStore(path, key)
+		So(err, ShouldBeNil)
+		So(s.Save(tok), ShouldBeNil)
+
+		b, err := os.ReadFile(path)
+		So(err, ShouldBeNil)
+		So(bytes.Contains(b, []byte("refresh")), ShouldBeFalse)
+
+		got, err := s.Load()
+		So(err, ShouldBeNil)
+		So(got.AccessToken, ShouldEqual, "access")
+
+		other, err := NewEncryptedFileTokenStore(path, bytes.Repeat([]byte{8}, 32))
+		So(err, ShouldBeNil)
+		_, err = other.Load()
+		So(err, ShouldNotBeNil)
+
+		_, err = NewEncryptedFileTokenStore(path, []byte("short"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should save refreshed tokens back to the store", t, func() {
+		refreshes := 0
+		auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			refreshes++
+			if err := req.ParseForm(); err != nil || req.Form.Get("refresh_token") != "old-refresh" {
+				http.Error(w, "bad refresh token", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"new-access","refresh_token":"new-refresh","token_type":"Bearer","expires_in":28800}`))
+		}))
+		defer auth.Close()
+		api := serveHTTP(t)
+		defer api.Close()
+
+		store := NewFileTokenStore(filepath.Join(t.TempDir(), "tesla.token"))
+		So(store.Save(&oauth2.Token{
+			AccessToken:  "old-access",
+			RefreshToken: "old-refresh",
+			Expiry:       time.Now().Add(-time.Hour),
+		}), ShouldBeNil)
+
+		var notified []string
+		client, err := NewClient(context.Background(),
+			WithOAuth2Config(&oauth2.Config{
+				ClientID: "ownerapi",
+				Endpoint: oauth2.Endpoint{TokenURL: auth.URL, AuthStyle: oauth2.AuthStyleInParams},
+			}),
+			WithTokenStore(store),
+			WithTokenHandler(func(t *oauth2.Token) { notified = append(notified, t.RefreshToken) }),
+			WithBaseURL(api.URL+"/api/1"),
+		)
+		So(err, ShouldBeNil)
+		So(notified, ShouldBeEmpty)
+
+		_, err = client.Vehicles()
+		So(err, ShouldBeNil)
+		_, err = client.Vehicles()
+		So(err, ShouldBeNil)
+		So(refreshes, ShouldEqual, 1)
+		So(notified, ShouldResemble, []string{"new-refresh"})
+
+		saved, err := store.Load()
+		So(err, ShouldBeNil)
+		So(saved.RefreshToken, ShouldEqual, "new-refresh")
+	})
+
+	Convey("Should save a token not read from the store", t, func() {
+		store := NewMemoryTokenStore(nil)
+		_, err := NewClient(context.Background(),
+			WithToken(&oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}),
+			WithTokenStore(store),
+		)
+		So(err, ShouldBeNil)
+		saved, err := store.Load()
+		So(err, ShouldBeNil)
+		So(saved.AccessToken, ShouldEqual, "abc")
+
+		_, err = NewClient(context.Background(), WithTokenStore(NewMemoryTokenStore(nil)))
+		So(err, ShouldNotBeNil)
+	})
+}
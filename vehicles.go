@@ -1,6 +1,7 @@
 package tesla
 
 import (
+	"context"
 	"strconv"
 	"strings"
 )
@@ -101,8 +102,13 @@ type VehiclesResponse struct {
 
 // Vehicles fetches the vehicles associated to a Tesla account via the API.
 func (c *Client) Vehicles() ([]*Vehicle, error) {
+	return c.VehiclesContext(context.Background())
+}
+
+// VehiclesContext is like Vehicles but uses ctx for the request.
+func (c *Client) VehiclesContext(ctx context.Context) ([]*Vehicle, error) {
 	vehiclesResponse := &VehiclesResponse{}
-	if err := c.getJSON(c.baseURL+"/vehicles", vehiclesResponse); err != nil {
+	if err := c.getJSON(ctx, c.baseURL+"/vehicles", vehiclesResponse); err != nil {
 		return nil, err
 	}
 	for _, v := range vehiclesResponse.Response {
@@ -113,8 +119,13 @@ func (c *Client) Vehicles() ([]*Vehicle, error) {
 
 // Vehicle fetches the vehicle by ID associated to a Tesla account via the API.
 func (c *Client) Vehicle(vehicleID int64) (*Vehicle, error) {
+	return c.VehicleContext(context.Background(), vehicleID)
+}
+
+// VehicleContext is like Vehicle but uses ctx for the request.
+func (c *Client) VehicleContext(ctx context.Context, vehicleID int64) (*Vehicle, error) {
 	resp := &VehicleResponse{}
-	if err := c.getJSON(c.baseURL+"/vehicles/"+strconv.FormatInt(vehicleID, 10), resp); err != nil {
+	if err := c.getJSON(ctx, c.baseURL+"/vehicles/"+strconv.FormatInt(vehicleID, 10), resp); err != nil {
 		return nil, err
 	}
 	resp.Response.c = c
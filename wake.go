@@ -0,0 +1,100 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// VehicleStateOnline is the Vehicle.State reported once the vehicle is awake.
+const VehicleStateOnline = "online"
+
+// WakeOptions controls how WakeAndWait wakes a vehicle. Zero values fall back
+// to the defaults documented on each field.
+type WakeOptions struct {
+	// PollInterval is the delay between vehicle state checks. Defaults to 2s.
+	PollInterval time.Duration
+	// WakeInterval is how often the wake_up command is re-issued while the
+	// vehicle is still asleep. Defaults to 10s.
+	WakeInterval time.Duration
+	// Timeout bounds the whole operation in addition to the context deadline.
+	// Zero means no additional bound.
+	Timeout time.Duration
+}
+
+func (o *WakeOptions) withDefaults() WakeOptions {
+	var out WakeOptions
+	if o != nil {
+		out = *o
+	}
+	if out.PollInterval <= 0 {
+		out.PollInterval = 2 * time.Second
+	}
+	if out.WakeInterval <= 0 {
+		out.WakeInterval = 10 * time.Second
+	}
+	return out
+}
+
+// WithAutoWake makes commands and Data calls that fail because the vehicle is
+// asleep wake the vehicle using WakeAndWait with opts and retry once.
+func WithAutoWake(opts *WakeOptions) ClientOption {
+	return func(c *Client) error {
+		o := opts.withDefaults()
+		c.autoWake = &o
+		return nil
+	}
+}
+
+// WakeAndWait wakes up the vehicle and blocks until it reports itself online,
+// re-issuing the wake_up command periodically. It returns the refreshed vehicle,
+// or the context error if ctx is done before the vehicle comes online.
+func (v *Vehicle) WakeAndWait(ctx context.Context, opts *WakeOptions) (*Vehicle, error) {
+	o := opts.withDefaults()
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	var lastWake time.Time
+	for {
+		if time.Since(lastWake) >= o.WakeInterval {
+			woken, err := v.WakeupContext(ctx)
+			if err != nil && !errors.Is(err, ErrVehicleUnavailable) {
+				return nil, err
+			}
+			if err == nil && woken.State == VehicleStateOnline {
+				v.State = woken.State
+				return woken, nil
+			}
+			lastWake = time.Now()
+		}
+
+		if err := sleepContext(ctx, o.PollInterval); err != nil {
+			return nil, err
+		}
+
+		vehicle, err := v.c.VehicleContext(ctx, v.ID)
+		if err != nil && !errors.Is(err, ErrVehicleUnavailable) {
+			return nil, err
+		}
+		if err == nil && vehicle.State == VehicleStateOnline {
+			v.State = vehicle.State
+			return vehicle, nil
+		}
+	}
+}
+
+// Runs fn and, if it failed because the vehicle is asleep and the client is
+// configured to wake vehicles automatically, wakes the vehicle and runs it again.
+func (v *Vehicle) withWake(ctx context.Context, fn func() error) error {
+	err := fn()
+	if err == nil || v.c.autoWake == nil || !errors.Is(err, ErrVehicleUnavailable) {
+		return err
+	}
+	if _, err := v.WakeAndWait(ctx, v.c.autoWake); err != nil {
+		return err
+	}
+	return fn()
+}
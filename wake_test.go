@@ -0,0 +1,133 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sleepyVehicle simulates a vehicle that comes online after a number of polls.
+type sleepyVehicle struct {
+	polls     int32
+	wakes     int32
+	onlineAt  int32
+	dataCalls int32
+}
+
+func (s *sleepyVehicle) online() bool {
+	return atomic.LoadInt32(&s.polls) >= s.onlineAt
+}
+
+func (s *sleepyVehicle) vehicleJSON() string {
+	if s.online() {
+		return VehicleJSON
+	}
+	return strings.Replace(VehicleJSON, `"state":"online"`, `"state":"asleep"`, 1)
+}
+
+func (s *sleepyVehicle) mux() *http.ServeMux {
+	mux := new(http.ServeMux)
+	mux.HandleFunc("/api/1/vehicles", serveJSON(VehiclesJSON))
+	mux.HandleFunc("/api/1/vehicles/1234", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&s.polls, 1)
+		serveJSON(s.vehicleJSON())(w, req)
+	})
+	mux.HandleFunc("/api/1/vehicles/1234/wake_up", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&s.wakes, 1)
+		serveJSON(s.vehicleJSON())(w, req)
+	})
+	mux.HandleFunc("/api/1/vehicles/1234/vehicle_data", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&s.dataCalls, 1)
+		if !s.online() {
+			serveStatus(http.StatusRequestTimeout, VehicleUnavailableJSON)(w, req)
+			return
+		}
+		serveJSON(DataJSON)(w, req)
+	})
+	mux.HandleFunc("/api/1/vehicles/1234/command/honk_horn", func(w http.ResponseWriter, req *http.Request) {
+		if !s.online() {
+			serveStatus(http.StatusRequestTimeout, VehicleUnavailableJSON)(w, req)
+			return
+		}
+		serveJSON(CommandResponseJSON)(w, req)
+	})
+	return mux
+}
+
+var testWakeOptions = &WakeOptions{
+	PollInterval: time.Millisecond,
+	WakeInterval: 2 * time.Millisecond,
+}
+
+func TestWakeAndWaitSpec(t *testing.T) {
+	Convey("Should poll until the vehicle is online", t, func() {
+		s := &sleepyVehicle{onlineAt: 3}
+		ts := httptest.NewServer(s.mux())
+		defer ts.Close()
+
+		vehicle := &Vehicle{ID: 1234, c: NewTestClient(ts)}
+		woken, err := vehicle.WakeAndWait(context.Background(), testWakeOptions)
+		So(err, ShouldBeNil)
+		So(woken.State, ShouldEqual, VehicleStateOnline)
+		So(vehicle.State, ShouldEqual, VehicleStateOnline)
+		So(atomic.LoadInt32(&s.wakes), ShouldBeGreaterThanOrEqualTo, 1)
+	})
+
+	Convey("Should give up when the deadline passes", t, func() {
+		s := &sleepyVehicle{onlineAt: 1 << 30}
+		ts := httptest.NewServer(s.mux())
+		defer ts.Close()
+
+		vehicle := &Vehicle{ID: 1234, c: NewTestClient(ts)}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		_, err := vehicle.WakeAndWait(ctx, testWakeOptions)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+	})
+}
+
+func TestAutoWakeSpec(t *testing.T) {
+	Convey("Should return unavailable without auto wake", t, func() {
+		s := &sleepyVehicle{onlineAt: 2}
+		ts := httptest.NewServer(s.mux())
+		defer ts.Close()
+
+		vehicle := &Vehicle{ID: 1234, c: NewTestClient(ts)}
+		_, err := vehicle.Data()
+		So(errors.Is(err, ErrVehicleUnavailable), ShouldBeTrue)
+		So(atomic.LoadInt32(&s.wakes), ShouldEqual, 0)
+	})
+
+	Convey("Should wake the vehicle and retry data requests", t, func() {
+		s := &sleepyVehicle{onlineAt: 2}
+		ts := httptest.NewServer(s.mux())
+		defer ts.Close()
+
+		client := NewTestClient(ts)
+		So(WithAutoWake(testWakeOptions)(client), ShouldBeNil)
+		vehicle := &Vehicle{ID: 1234, c: client}
+		data, err := vehicle.Data()
+		So(err, ShouldBeNil)
+		So(data.Response.DriveState.Latitude, ShouldEqual, 35.1)
+		So(atomic.LoadInt32(&s.dataCalls), ShouldEqual, 2)
+	})
+
+	Convey("Should wake the vehicle and retry commands", t, func() {
+		s := &sleepyVehicle{onlineAt: 2}
+		ts := httptest.NewServer(s.mux())
+		defer ts.Close()
+
+		client := NewTestClient(ts)
+		So(WithAutoWake(testWakeOptions)(client), ShouldBeNil)
+		vehicle := &Vehicle{ID: 1234, c: client}
+		So(vehicle.HonkHorn(), ShouldBeNil)
+		So(atomic.LoadInt32(&s.wakes), ShouldBeGreaterThanOrEqualTo, 1)
+	})
+}